@@ -2,94 +2,122 @@ package main
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
-	"log"
+	"io/fs"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	goRunTime "runtime"
-
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ppatcher/semver"
+	"ppatcher/updater"
 )
 
 type App struct {
-	ctx  context.Context
-	meta MetaData
+	ctx      context.Context
+	products []*product
+	// selected is the ID of the product the single-product bound methods
+	// and events refer to, guarded by selectedMu.
+	selected   string
+	selectedMu sync.RWMutex
 }
 
 func NewApp() *App {
-	return &App{}
+	app := &App{}
+	for _, config := range productConfigs() {
+		app.products = append(app.products, &product{app: app, config: config})
+	}
+	app.selected = app.products[0].config.ID
+	return app
 }
 
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-
-	if BuildConfig.Mode != "dev" {
-		exeDir, err := os.Executable()
-		if err != nil {
-			log.Fatal("Could not get executable path: ", err)
-		}
-
-		exeDir = filepath.Dir(exeDir)
-
-		err = os.Chdir(exeDir)
-		if err != nil {
-			log.Fatal("Could not change working directory: ", err)
+	for _, p := range a.products {
+		p.loadToken()
+		root := resolveInstallDir(p)
+		p.setRoot(root)
+		p.loadPin()
+		if root != "" {
+			if err := os.MkdirAll(root, os.ModePerm); err != nil {
+				logger.Error("creating install directory failed", "product", p.config.ID, "dir", root, "err", err)
+			}
 		}
 	}
 
 	runtime.EventsOn(a.ctx, "ready", func(optionalData ...interface{}) {
-		a.ManualUpdate()
+		// Translations are served by the backend of the default product.
+		go a.products[0].fetchTranslations()
+
+		for _, p := range a.products {
+			// On first run the frontend asks for an install location and
+			// starts the update itself once one is set.
+			if p.rootDir() == "" {
+				if p == a.current() {
+					runtime.EventsEmit(a.ctx, "installDirRequired", defaultInstallDir(p))
+				}
+				continue
+			}
+			p.manualUpdate()
+		}
 	})
+
+	for _, p := range a.products {
+		go p.watchUpdates(ctx)
+		go p.subscribeEvents(ctx)
+	}
 }
 
 func (a *App) Config() (buildConfig Config) {
-	return *BuildConfig
+	buildConfig = *BuildConfig
+	// The version shown is the latest one the backend reported.
+	if p := a.product(defaultProductID); p != nil {
+		buildConfig.Version = p.version()
+	}
+	return buildConfig
 }
 
-func (a *App) tryUpdating() (err error) {
+func (p *product) tryUpdating(rel *release) (err error) {
 
-	a.UpdateDownloadStatus("checking")
-	ShouldUpdate, err := a.ShouldUpdate()
+	p.setStatus("checking")
+	// The version comes first: a mandatory release is downloaded even if
+	// the player rolled back from it.
+	p.fetchRemoteVersion()
+	ShouldUpdate, err := p.shouldUpdate(rel)
 
+	if errors.Is(err, errLicenseRequired) {
+		p.requireActivation(err)
+		return err
+	}
 	if err != nil {
-		a.UpdateDownloadStatus("error")
-		if BuildConfig.Mode != "production" {
-			log.Println("Error checking for updates:", err)
-		}
+		p.setError(err)
+		logger.Error("update check failed", "product", p.config.ID, "err", err)
 		return err
 	}
 
-	// Fetch the current version from the server and update config if it changed.
-	a.fetchRemoteVersion()
+	p.fetchNews()
 
 	if ShouldUpdate {
-		a.UpdateDownloadStatus("downloading")
-		if BuildConfig.Mode != "production" {
-			log.Println("We should update the files")
-		}
-		return a.Update()
+		p.setStatus("downloading")
+		logger.Info("update required", "product", p.config.ID)
+		return p.update(rel)
 	}
 
-	a.UpdateDownloadStatus("alreadyReady")
+	p.setStatus("alreadyReady")
 
 	return nil
 }
 
 // fetchRemoteVersion queries {backend}/version and, if the version differs from
-// the baked-in config, updates BuildConfig.Version and emits a "versionUpdate" event.
-func (a *App) fetchRemoteVersion() {
-	resp, err := http.Get(BuildConfig.Backend + "/version")
+// the known one, records it and emits a "versionUpdate" event.
+func (p *product) fetchRemoteVersion() {
+	resp, err := p.get("/version")
 	if err != nil {
 		return
 	}
@@ -98,7 +126,9 @@ func (a *App) fetchRemoteVersion() {
 		return
 	}
 	var payload struct {
-		Version string `json:"version"`
+		Version        string `json:"version"`
+		MinimumVersion string `json:"minimumVersion"`
+		Mandatory      bool   `json:"mandatory"`
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -107,66 +137,53 @@ func (a *App) fetchRemoteVersion() {
 	if err := json.Unmarshal(body, &payload); err != nil || payload.Version == "" {
 		return
 	}
-	if payload.Version != BuildConfig.Version {
-		BuildConfig.Version = payload.Version
-		runtime.EventsEmit(a.ctx, "versionUpdate", payload.Version)
+	if _, ok := semver.Parse(payload.Version); !ok {
+		logger.Debug("remote version is not a semantic version", "product", p.config.ID, "version", payload.Version)
 	}
+	p.setVersionPolicy(payload.MinimumVersion, payload.Mandatory)
+	if payload.Version != p.version() {
+		p.setVersion(payload.Version)
+		p.emit("versionUpdate", payload.Version)
+	}
+	p.emitVersionStatus()
 }
 
 type MetaData struct {
-	Hash      string `json:"hash"`
-	TotalSize int64  `json:"totalSize"`
-}
-
-type MetaDataForFiles struct {
-	Files []MetaForFile `json:"files"`
+	Hash      string   `json:"hash"`
+	TotalSize int64    `json:"totalSize"`
+	Groups    []string `json:"groups,omitempty"`
+	// Version is the release version the files were installed from.
+	Version string `json:"version,omitempty"`
+	// Skip is the hash of a release the player rolled back from. It isn't
+	// downloaded again until the backend publishes a different one.
+	Skip string `json:"skip,omitempty"`
+	// Pinned is the version chosen with InstallVersion. Pinned installs
+	// aren't moved to the latest release.
+	Pinned string `json:"pinned,omitempty"`
 }
 
-type MetaForFile struct {
-	Hash string
-	Path string
-	Size int64
-}
-
-var (
-	bufferPool = sync.Pool{
-		New: func() interface{} {
-			return make([]byte, 32*1024) // 32KB buffers
-		},
-	}
+// MetaDataForFiles and MetaForFile are the release manifest and its
+// entries, as read by the updater package.
+type (
+	MetaDataForFiles = updater.Manifest
+	MetaForFile      = updater.File
 )
 
-// getBackendURLs returns the primary backend followed by any fallback URLs.
-func getBackendURLs() []string {
-	urls := []string{BuildConfig.Backend}
-	urls = append(urls, BuildConfig.FallbackURLs...)
-	return urls
-}
-
-func calculateFilesMeta() ([]MetaForFile, int64, error) {
+func (p *product) calculateFilesMeta(rel *release) ([]MetaForFile, int64, error) {
 	var filesMeta []MetaForFile
 	var totalSize int64
 
-	metaDataForFiles, _ := FetchFilesMeta()
+	metaDataForFiles, _ := rel.files()
 	if metaDataForFiles != nil {
 		for _, fileMeta := range metaDataForFiles.Files {
-			path := fileMeta.Path
-
-			hash, size, err := calculateFileHash(path)
-			if err != nil {
-				continue
-			}
-
-			relPath, err := filepath.Rel("./", path)
+			hash, size, err := updater.HashFile(p.path(fileMeta.Path))
 			if err != nil {
 				continue
 			}
 
-			relPath = filepath.ToSlash(relPath)
-
 			fileMeta := MetaForFile{
 				Hash: hash,
-				Path: relPath,
+				Path: fileMeta.Path,
 				Size: size,
 			}
 
@@ -177,24 +194,28 @@ func calculateFilesMeta() ([]MetaForFile, int64, error) {
 		return filesMeta, totalSize, nil
 	}
 
-	err := filepath.Walk("./", func(path string, info os.FileInfo, err error) error {
+	root := p.rootDir()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip directories and backups
 		if info.IsDir() {
+			if path == p.path(backupDir) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
 		// Calculate file hash
-		hash, _, err := calculateFileHash(path)
+		hash, _, err := updater.HashFile(path)
 		if err != nil {
 			return err
 		}
 
 		// Get relative path
-		relPath, err := filepath.Rel("./", path)
+		relPath, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
@@ -217,230 +238,238 @@ func calculateFilesMeta() ([]MetaForFile, int64, error) {
 	return filesMeta, totalSize, err
 }
 
-func calculateOverallHash(filesMeta []MetaForFile) (string, error) {
-	hash := md5.New()
-
-	for _, fileMeta := range filesMeta {
-		// Include file path, hash, and size in the overall hash calculation
-		hash.Write([]byte(fileMeta.Path))
-		hash.Write([]byte(fileMeta.Hash))
-
-		// Write size as 8 bytes (int64)
-		size := fileMeta.Size
-		for i := 0; i < 8; i++ {
-			hash.Write([]byte{byte(size)})
-			size >>= 8
-		}
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
-func generateMetaFile() error {
-	filesMeta, totalSize, err := calculateFilesMeta()
+func (p *product) generateMetaFile(rel *release) error {
+	filesMeta, totalSize, err := p.calculateFilesMeta(rel)
 	if err != nil {
 		return err
 	}
 
-	overallHash, err := calculateOverallHash(filesMeta)
-	if err != nil {
-		return err
-	}
+	overallHash := updater.OverallHash(filesMeta)
 
 	meta := MetaData{
 		Hash:      overallHash,
 		TotalSize: totalSize,
 	}
 
+	// Keep what only the update itself knows.
+	if data, err := os.ReadFile(p.path(".downloadmeta")); err == nil {
+		var previous MetaData
+		json.Unmarshal(data, &previous)
+		meta.Version, meta.Skip, meta.Pinned = previous.Version, previous.Skip, previous.Pinned
+	}
+
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(".downloadmeta", metaJSON, 0644); err != nil {
+	if err := os.WriteFile(p.path(".downloadmeta"), metaJSON, 0644); err != nil {
 		return err
 	}
 
-	if BuildConfig.Mode != "production" {
-		log.Printf("Local meta file updated successfully. Total size: %d, Hash: %s", totalSize, overallHash)
-	}
+	logger.Debug("local meta file updated", "product", p.config.ID, "totalSize", totalSize, "hash", overallHash)
 	return nil
 }
 
-func calculateFileHash(filePath string) (string, int64, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", 0, err
-	}
-	defer file.Close()
+func (a *App) UpdateDownloadStatus(status string) {
+	a.current().setStatus(status)
+}
 
-	var totalSize int64 = 0
-	hash := md5.New()
-	buf := bufferPool.Get().([]byte)
-	defer bufferPool.Put(buf)
+func (a *App) UpdateDownloadProgress(progress float64) {
+	a.current().setProgress(progress)
+}
 
-	for {
-		n, err := file.Read(buf)
-		totalSize += int64(n)
-		if n > 0 {
-			hash.Write(buf[:n])
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", 0, err
-		}
-	}
+func (a *App) UpdateCurrentFileData(path string, size int64) {
+	a.current().setCurrentFile(path, size)
+}
 
-	return hex.EncodeToString(hash.Sum(nil)), totalSize, nil
+func (p *product) setStatus(status string) {
+	logger.Info("download status", "product", p.config.ID, "status", status)
+	p.mu.Lock()
+	p.status = status
+	p.mu.Unlock()
+	p.emit("downloadStatus", status)
+	p.emit("statusMessage", T("status."+status))
+	p.emitVersionStatus()
 }
-func (a *App) UpdateDownloadStatus(status string) {
-	if BuildConfig.Mode != "production" {
-		log.Println("Download status:", status)
-	}
-	runtime.EventsEmit(a.ctx, "downloadStatus", status)
+
+// setError sets the "error" status and emits the reason, in the player's
+// language, as a "downloadError" event.
+func (p *product) setError(err error) {
+	p.setStatus("error")
+	p.emit("downloadError", errorMessage(err))
 }
 
-func (a *App) UpdateDownloadProgress(progress float64) {
-	if BuildConfig.Mode != "production" {
-		log.Println("Download progress:", progress)
-	}
-	runtime.EventsEmit(a.ctx, "downloadProgress", progress)
+func (p *product) setProgress(progress float64) {
+	logger.Debug("download progress", "product", p.config.ID, "progress", progress)
+	p.emit("downloadProgress", progress)
 }
 
-func (a *App) UpdateCurrentFileData(path string, size int64) {
-	runtime.EventsEmit(a.ctx, "currentFileData", map[string]interface{}{
+func (p *product) setCurrentFile(path string, size int64) {
+	p.emit("currentFileData", map[string]interface{}{
 		"path": path,
 		"size": size,
 	})
 }
 
 func (a *App) ShouldUpdate() (should bool, err error) {
-	backend := BuildConfig.Backend
-	if BuildConfig.Mode != "production" {
-		log.Println("Checking for updates from backend:", backend)
-	}
-	resp, err := http.Get(backend + "/meta")
+	p := a.current()
+	return p.shouldUpdate(&release{p: p})
+}
 
-	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error checking for updates:", err)
+func (p *product) shouldUpdate(rel *release) (should bool, err error) {
+	defer func() {
+		if err == nil {
+			p.setOutdated(should)
 		}
+	}()
+	logger.Info("checking for updates", "product", p.config.ID, "backend", p.config.Backend)
+	pinned := p.pinnedVersion()
+	remote, err := p.client().Meta(context.Background())
+	var statusErr *updater.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound && pinned != "" {
+		logger.Error("pinned version is not available", "product", p.config.ID, "version", pinned)
+		return false, newLocalError("error.versionNotFound", "version", pinned)
+	}
+	if err != nil {
+		logger.Error("update check failed", "product", p.config.ID, "err", err)
 		return false, err
 	}
-	defer resp.Body.Close()
+	meta := MetaData{Hash: remote.Hash, TotalSize: remote.TotalSize, Groups: remote.Groups}
 
-	if resp.StatusCode != http.StatusOK {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error checking for updates: status code", resp.StatusCode)
+	// With optional components the server's overall hash covers files the
+	// player may not have selected, so compare against the selected subset.
+	if len(meta.Groups) > 0 {
+		filesMeta, err := rel.files()
+		if err != nil {
+			return false, err
 		}
-		return false, fmt.Errorf("status code %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error reading response body:", err)
+		meta, err = selectedMeta(filesMeta.Files)
+		if err != nil {
+			return false, err
 		}
-		return false, err
 	}
+	p.setMeta(meta)
 
-	json.Unmarshal(body, &a.meta)
+	logger.Debug("remote meta", "product", p.config.ID, "hash", meta.Hash, "totalSize", meta.TotalSize)
 
-	if BuildConfig.Mode != "production" {
-		log.Println("File hash:", a.meta.Hash)
-	}
-	if BuildConfig.Mode != "production" {
-		log.Println("File total size:", a.meta.TotalSize)
-	}
-
-	data, err := os.ReadFile(".downloadmeta")
+	data, err := os.ReadFile(p.path(".downloadmeta"))
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("No local meta file found, need to download the files")
-		}
+		logger.Info("no local meta file, files need downloading", "product", p.config.ID)
 		return true, nil
 	}
 
 	var localMeta MetaData
 	json.Unmarshal(data, &localMeta)
 
-	if localMeta.Hash != a.meta.Hash || localMeta.TotalSize != a.meta.TotalSize {
-		if BuildConfig.Mode != "production" {
-			log.Println(localMeta.Hash, a.meta.Hash)
-		}
-		if BuildConfig.Mode != "production" {
-			log.Println("Local meta does not match remote meta, need to download the files")
-		}
+	if localMeta.Skip != "" && localMeta.Skip == meta.Hash && pinned == "" && !p.updateForced(localMeta.Version) {
+		logger.Info("remote release was rolled back, not downloading it again", "product", p.config.ID, "hash", meta.Hash)
+		return false, nil
+	}
+
+	if localMeta.Hash != meta.Hash || localMeta.TotalSize != meta.TotalSize {
+		logger.Info("local meta differs from remote meta, files need downloading", "product", p.config.ID, "localHash", localMeta.Hash, "remoteHash", meta.Hash)
 		return true, nil
 	}
 
-	if BuildConfig.Mode != "production" {
-		log.Println("Local meta matches remote meta, no need to download the files")
+	logger.Info("local meta matches remote meta", "product", p.config.ID)
+
+	// The installed files are the backend's current release, also when
+	// only its version changed, or the pinned one.
+	p.mu.RLock()
+	version := p.remoteVersion
+	p.mu.RUnlock()
+	if pinned != "" {
+		version = pinned
+	}
+	if (version != "" && localMeta.Version != version) || localMeta.Pinned != pinned {
+		localMeta.Version, localMeta.Pinned = version, pinned
+		if data, err := json.Marshal(localMeta); err == nil {
+			if err := os.WriteFile(p.path(".downloadmeta"), data, 0644); err != nil {
+				logger.Warn("recording installed version failed", "product", p.config.ID, "err", err)
+			}
+		}
 	}
 	return false, nil
 }
 
 func (a *App) ManualUpdate() (err error) {
-	err = generateMetaFile()
-	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error generating local meta file", err)
-		}
-		return err
-	}
-
-	return a.tryUpdating()
+	return a.current().manualUpdate()
 }
 
-func FetchFilesMeta() (filesMeta *MetaDataForFiles, err error) {
-	backend := BuildConfig.Backend
-	resp, err := http.Get(backend + "/filesmeta")
-	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error fetching files meta:", err)
-		}
-		return nil, err
+func (p *product) manualUpdate() (err error) {
+	if p.rootDir() == "" {
+		return errNoInstallDir
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error fetching files meta: status code", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	if !p.updating.TryLock() {
+		return errUpdateInProgress
 	}
+	defer p.updating.Unlock()
 
-	body, err := io.ReadAll(resp.Body)
+	rel := &release{p: p}
+	err = p.generateMetaFile(rel)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error reading response body:", err)
-		}
-		return nil, err
+		logger.Error("generating local meta file failed", "product", p.config.ID, "err", err)
+		return err
 	}
 
-	json.Unmarshal(body, &filesMeta)
+	return p.tryUpdating(rel)
+}
 
-	return filesMeta, nil
+// release is the backend's release with the files the player selected. It
+// is fetched once, when a step of a check or update first needs it, and
+// shared by the later ones.
+type release struct {
+	p         *product
+	filesMeta *MetaDataForFiles
+	err       error
+}
+
+func (r *release) files() (*MetaDataForFiles, error) {
+	if r.filesMeta == nil && r.err == nil {
+		r.filesMeta, r.err = r.p.fetchSelectedFiles()
+	}
+	return r.filesMeta, r.err
 }
 
 func (a *App) Update() (err error) {
-	backend := BuildConfig.Backend
-	if BuildConfig.Mode != "production" {
-		log.Println("Starting update from backend:", backend)
+	p := a.current()
+	if p.rootDir() == "" {
+		return errNoInstallDir
 	}
-	filesMeta, err := FetchFilesMeta()
+	if !p.updating.TryLock() {
+		return errUpdateInProgress
+	}
+	defer p.updating.Unlock()
+
+	return p.update(&release{p: p})
+}
+
+func (p *product) update(rel *release) (err error) {
+	logger.Info("starting update", "product", p.config.ID, "backend", p.config.Backend)
+	started := time.Now()
+	var report *UpdateReport
+	defer func() {
+		p.sendTelemetry(report, started, err)
+	}()
+
+	filesMeta, err := rel.files()
 	if err != nil {
 		return err
 	}
 
+	files := filesMeta.Files
+	report = newUpdateReport(p, len(files))
+	previous := p.loadInstalledFiles()
+	backup := p.beginBackup(previous)
+
 	var totalDownloaded int64 = 0
-	var totalSize int64 = a.meta.TotalSize
-	var fileCount int64 = int64(len(filesMeta.Files))
+	var totalSize int64 = p.remoteMeta().TotalSize
+	var fileCount int64 = int64(len(files))
 
-	var lastFilePath string = ""
-	var lastFileSize int64 = 0
+	// lastFile is the file being downloaded, set by the download workers.
+	var lastFile atomic.Pointer[MetaForFile]
+	lastFile.Store(&MetaForFile{})
 
 	go func() {
 		for {
@@ -449,231 +478,160 @@ func (a *App) Update() (err error) {
 				break
 			}
 			time.Sleep(100 * time.Millisecond)
-			a.UpdateDownloadProgress(float64(atomic.LoadInt64(&totalDownloaded)) / float64(totalSize))
-			a.UpdateCurrentFileData(lastFilePath, lastFileSize)
+			p.setProgress(float64(atomic.LoadInt64(&totalDownloaded)) / float64(totalSize))
+			file := lastFile.Load()
+			p.setCurrentFile(file.Path, file.Size)
 		}
 	}()
 
-	maxConcurrentDownloads := 10
-	semaphore := make(chan struct{}, maxConcurrentDownloads)
-	var wg sync.WaitGroup
-
-	for _, file := range filesMeta.Files {
-		wg.Add(1)
-		file := file
-
-		go func() {
-			defer atomic.AddInt64(&totalDownloaded, file.Size)
-			defer atomic.AddInt64(&fileCount, -1)
-
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			lastFilePath = file.Path
-			lastFileSize = file.Size
-			hash, _, err := calculateFileHash(file.Path)
-
-			if err != nil {
-				if BuildConfig.Mode != "production" {
-					log.Println("Error calculating the hash for the file", file.Path)
-				}
-				hash = ""
-			}
-
-			if hash == file.Hash {
-				if BuildConfig.Mode != "production" {
-					log.Println("File is up to date, skipping", file.Path)
-				}
-				return
+	// finish counts a file toward the progress once it is handled.
+	finish := func(file MetaForFile) {
+		atomic.AddInt64(&totalDownloaded, file.Size)
+		atomic.AddInt64(&fileCount, -1)
+	}
+
+	client := p.client()
+	client.Blobs = filesMeta.Blobs
+	client.Sink = updater.SinkFunc(func(event updater.Event) {
+		switch event.Kind {
+		case updater.EventUpToDate:
+			report.skip()
+			finish(event.File)
+		case updater.EventDownloading:
+			file := event.File
+			lastFile.Store(&file)
+		case updater.EventRetrying:
+			if event.Attempt == 2 {
+				report.retry()
 			}
+		case updater.EventDownloaded:
+			report.download(event.File.Size)
+			finish(event.File)
+		case updater.EventFailed:
+			report.fail(event.File.Path, event.Err)
+			finish(event.File)
+		}
+		if p.sink != nil {
+			p.sink.Event(event)
+		}
+	})
 
-			err = a.downloadFile(backend, file.Path)
-			if err != nil {
-				if BuildConfig.Mode != "production" {
-					log.Println("Error downloading file:", file.Path, err)
-				}
+	changes, err := client.Diff(context.Background(), files)
+	if err != nil {
+		atomic.StoreInt64(&fileCount, 0)
+		return err
+	}
+	if backup != nil {
+		for _, change := range changes {
+			if change.LocalHash == "" {
+				backup.add(change.File.Path)
+			} else {
+				backup.save(p, change.File.Path, change.LocalHash, change.LocalSize)
 			}
-		}()
+		}
 	}
 
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Failures are counted in the report.
+		client.Download(context.Background(), updater.ChangedFiles(changes))
+	}()
+
 	// Save the new meta file
-	respMeta, err := http.Get(backend + "/meta")
+	installedMeta, err := p.fetchInstalledMeta(filesMeta, files)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error fetching meta:", err)
-		}
 		return err
 	}
-	defer respMeta.Body.Close()
-
-	if respMeta.StatusCode != http.StatusOK {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error fetching meta: status code", respMeta.StatusCode)
-		}
-		return fmt.Errorf("status code %d", respMeta.StatusCode)
+	installedMeta.Version = p.version()
+	installedMeta.Pinned = p.pinnedVersion()
+	if installedMeta.Pinned != "" {
+		installedMeta.Version = installedMeta.Pinned
 	}
-
-	metaBody, err := io.ReadAll(respMeta.Body)
+	metaBody, err := json.Marshal(installedMeta)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error reading meta response body:", err)
-		}
 		return err
 	}
 
-	err = os.WriteFile(".downloadmeta", metaBody, 0644)
+	err = os.WriteFile(p.path(".downloadmeta"), metaBody, 0644)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error writing local meta file:", err)
-		}
+		logger.Error("writing local meta file failed", "product", p.config.ID, "err", err)
 		return err
 	}
 
 	wg.Wait()
 
-	a.UpdateDownloadStatus("ready")
-	return nil
-}
-
-func (a *App) StartExecutable() {
-	executablePath := strings.TrimSpace(BuildConfig.Executable)
-
-	if executablePath == "" {
-		if BuildConfig.Mode != "production" {
-			log.Println("executable path is empty")
-		}
-		return
+	p.removeDroppedFiles(previous, files, backup)
+	if err := p.saveInstalledFiles(files); err != nil {
+		logger.Warn("writing installed manifest failed", "product", p.config.ID, "err", err)
 	}
-
-	if !filepath.IsAbs(executablePath) {
-		absPath, err := filepath.Abs(executablePath)
-		if err != nil {
-			if BuildConfig.Mode != "production" {
-				log.Printf("failed to get absolute path: %v", err)
-			}
-			return
-		}
-		executablePath = absPath
+	if backup != nil {
+		backup.finish(p)
 	}
 
-	// Check if executable exists
-	if _, err := os.Stat(executablePath); os.IsNotExist(err) {
-		if BuildConfig.Mode != "production" {
-			log.Printf("executable not found: %s", executablePath)
-		}
-		return
-	}
+	p.finishReport(report)
+	p.setOutdated(false)
+	p.setStatus("ready")
+	return nil
+}
 
-	if BuildConfig.Mode != "production" {
-		log.Printf("Starting executable %s\n", executablePath)
+// fetchInstalledMeta returns the contents of .downloadmeta for the files
+// being installed: the server's /meta for a full install, or a summary of the
+// selected files when the release has optional components.
+func (p *product) fetchInstalledMeta(filesMeta *MetaDataForFiles, files []MetaForFile) (MetaData, error) {
+	if len(filesMeta.Groups) > 0 {
+		return selectedMeta(files)
 	}
 
-	// Start the executable using the absolute path
-	cmd := exec.Command(executablePath)
-	if err := cmd.Start(); err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Printf("failed to start executable: %v", err)
-		}
-		return
+	meta, err := p.client().Meta(context.Background())
+	if err != nil {
+		logger.Error("fetching meta failed", "product", p.config.ID, "err", err)
+		return MetaData{}, err
 	}
-
-	// Detach from the process
-	go func() {
-		err := cmd.Wait()
-		if err != nil {
-			if BuildConfig.Mode != "production" {
-				log.Printf("Executable finished with error: %v", err)
-			}
-		}
-		if BuildConfig.Mode != "production" {
-			log.Printf("Executable finished successfully")
-		}
-	}()
+	return MetaData{Hash: meta.Hash, TotalSize: meta.TotalSize, Groups: meta.Groups}, nil
 }
 
-func (a *App) downloadFile(backend string, path string) error {
-	if BuildConfig.Mode != "production" {
-		log.Println("Downloading file:", path)
-	}
+func (a *App) StartExecutable() {
+	a.current().launch("")
+}
 
-	resp, err := http.Get(backend + "/files/" + path)
+// launch starts the product's executable, or the one of the launch profile
+// with the given ID.
+func (p *product) launch(profileID string) error {
+	profile, err := p.launchProfile(profileID)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error downloading file:", path, err)
-		}
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error downloading file: status code", resp.StatusCode)
-		}
-		return fmt.Errorf("status code %d", resp.StatusCode)
+	if status := p.versionStatus(); status.State == versionRequired {
+		logger.Warn("launch blocked until the required update is installed", "product", p.config.ID, "installed", status.Installed, "latest", status.Latest, "minimum", status.MinimumVersion)
+		return newLocalError("error.updateRequired", "version", status.Latest)
 	}
+	logger.Info("starting executable", "product", p.config.ID, "path", profile.Executable, "args", profile.Args)
 
-	// Create the directories if they don't exist
-	if strings.Contains(path, "/") {
-		err = os.MkdirAll(getDir(path), os.ModePerm)
-		if err != nil {
-			if BuildConfig.Mode != "production" {
-				log.Println("Error creating directories for file:", path, err)
-			}
-			return err
-		}
+	cmd, err := p.client().Launch(profile.Executable, profile.Args...)
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && errors.Is(err, fs.ErrNotExist) {
+		logger.Error("executable not found", "product", p.config.ID, "path", pathErr.Path)
+		return newLocalError("error.executableNotFound", "path", pathErr.Path)
 	}
-
-	out, err := os.Create(path)
 	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error creating file:", path, err)
-		}
+		logger.Error("starting executable failed", "product", p.config.ID, "path", profile.Executable, "err", err)
 		return err
 	}
-	defer out.Close()
-
-	if goRunTime.GOOS != "windows" {
-		info, err := out.Stat()
-		if err == nil {
-			err = out.Chmod(info.Mode() | 0111)
-			if err != nil {
-				if BuildConfig.Mode != "production" {
-					log.Printf("Error giving exec permission to file %s", path)
-				}
-			}
-		} else {
-			if BuildConfig.Mode != "production" {
-				log.Printf("Error reading permission of file %s", path)
-			}
-		}
-	}
 
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		if BuildConfig.Mode != "production" {
-			log.Println("Error writing file:", path, err)
+	// Detach from the process
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logger.Warn("executable finished with error", "product", p.config.ID, "err", err)
 		}
-		return err
-	}
+		logger.Info("executable finished", "product", p.config.ID)
+	}()
 
 	return nil
 }
 
 func (a *App) BackendLog(s string) {
-	if BuildConfig.Mode != "production" {
-		log.Println("frontend log:", s)
-	}
-}
-
-// getDir returns the directory part of a file path
-func getDir(path string) string {
-	dir := ""
-	for i := len(path) - 1; i >= 0; i-- {
-		if path[i] == '/' || path[i] == '\\' {
-			dir = path[:i]
-			break
-		}
-	}
-	return dir
+	logger.Info("frontend log", "message", s)
 }
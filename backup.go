@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ppatcher/updater"
+)
+
+const (
+	// backupDir holds the snapshots of previous versions under the install
+	// root, so that files can be moved in and out without copying.
+	backupDir = ".backups"
+	// installedFilesFile records the manifest of the installed files, so
+	// that the next update knows which files were dropped from a release.
+	installedFilesFile = ".filesmeta"
+	snapshotFile       = "snapshot.json"
+
+	defaultBackupVersions = 1
+	defaultBackupSizeMB   = 2048
+)
+
+// BackupConfig controls the backups kept for RollbackToPrevious. Each update
+// moves the files it replaces or deletes into a snapshot of the previous
+// version.
+type BackupConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxVersions is the number of snapshots kept, newest first.
+	MaxVersions int `json:"maxVersions"`
+	// MaxSizeMB caps the disk space used by all snapshots together. Older
+	// snapshots are pruned first.
+	MaxSizeMB int64 `json:"maxSizeMB"`
+}
+
+// PreviousVersion describes the snapshot RollbackToPrevious would restore.
+type PreviousVersion struct {
+	Available bool      `json:"available"`
+	Version   string    `json:"version"`
+	Created   time.Time `json:"created"`
+}
+
+// backupSnapshot is the snapshot.json of one backed up version.
+type backupSnapshot struct {
+	Version string    `json:"version"`
+	Created time.Time `json:"created"`
+	// Meta is the .downloadmeta of the previous version.
+	Meta MetaData `json:"meta"`
+	// Files is the manifest the previous version was installed from, if it
+	// was recorded.
+	Files []MetaForFile `json:"files"`
+	// Saved lists the files kept in the snapshot, with the hash of the
+	// saved copy.
+	Saved []MetaForFile `json:"saved"`
+	// Added lists the files the update created.
+	Added []string `json:"added"`
+	Size  int64    `json:"size"`
+
+	dir    string
+	mu     sync.Mutex
+	broken bool
+}
+
+// backupSettings returns the backup limits with defaults applied.
+func backupSettings() (versions int, maxSize int64) {
+	config := BuildConfig.Backup
+	versions, maxSize = config.MaxVersions, config.MaxSizeMB
+	if versions <= 0 {
+		versions = defaultBackupVersions
+	}
+	if maxSize <= 0 {
+		maxSize = defaultBackupSizeMB
+	}
+	return versions, maxSize << 20
+}
+
+// loadInstalledFiles returns the recorded manifest of the installed files,
+// or nil if there is none.
+func (p *product) loadInstalledFiles() []MetaForFile {
+	data, err := os.ReadFile(p.path(installedFilesFile))
+	if err != nil {
+		return nil
+	}
+	var files []MetaForFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		logger.Warn("reading installed manifest failed", "product", p.config.ID, "err", err)
+		return nil
+	}
+	return files
+}
+
+func (p *product) saveInstalledFiles(files []MetaForFile) error {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path(installedFilesFile), data, 0644)
+}
+
+// removeDroppedFiles deletes the files of the previous manifest that are no
+// longer part of the release, moving them into the backup if there is one.
+func (p *product) removeDroppedFiles(previous, files []MetaForFile, backup *backupSnapshot) {
+	if backup == nil {
+		client := p.client()
+		client.Sink = p.sink
+		client.Cleanup(previous, files)
+		return
+	}
+	for _, file := range updater.Dropped(previous, files) {
+		backup.move(p, file.Path)
+	}
+}
+
+// beginBackup starts a snapshot of the installed version, or returns nil if
+// backups are disabled.
+func (p *product) beginBackup(previous []MetaForFile) *backupSnapshot {
+	if !BuildConfig.Backup.Enabled {
+		return nil
+	}
+	snapshot := &backupSnapshot{
+		Created: time.Now().UTC(),
+		Files:   previous,
+	}
+	if data, err := os.ReadFile(p.path(".downloadmeta")); err == nil {
+		json.Unmarshal(data, &snapshot.Meta)
+	}
+	snapshot.Version = snapshot.Meta.Version
+	snapshot.dir = p.path(filepath.Join(backupDir, snapshot.Created.Format("20060102-150405.000")))
+	return snapshot
+}
+
+func (s *backupSnapshot) savedPath(rel string) string {
+	return filepath.Join(s.dir, "files", filepath.FromSlash(rel))
+}
+
+// save keeps the current contents of a file that is about to be replaced.
+// The file is hard linked into the snapshot where possible, since the update
+// replaces the directory entry and leaves the old contents in place.
+func (s *backupSnapshot) save(p *product, rel, hash string, size int64) {
+	dst := s.savedPath(rel)
+	err := os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	if err == nil {
+		if err = os.Link(p.path(rel), dst); err != nil {
+			err = copyPath(p.path(rel), dst)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		logger.Warn("backing up file failed", "product", p.config.ID, "path", rel, "err", err)
+		s.broken = true
+		return
+	}
+	s.Saved = append(s.Saved, MetaForFile{Path: rel, Hash: hash, Size: size})
+	s.Size += size
+}
+
+// move moves a file that is being deleted into the snapshot.
+func (s *backupSnapshot) move(p *product, rel string) {
+	hash, size, err := updater.HashFile(p.path(rel))
+	if os.IsNotExist(err) {
+		return
+	}
+	dst := s.savedPath(rel)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	}
+	if err == nil {
+		err = os.Rename(p.path(rel), dst)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		logger.Warn("backing up dropped file failed", "product", p.config.ID, "path", rel, "err", err)
+		s.broken = true
+		return
+	}
+	s.Saved = append(s.Saved, MetaForFile{Path: rel, Hash: hash, Size: size})
+	s.Size += size
+}
+
+// add records a file the update creates, which a rollback removes again.
+func (s *backupSnapshot) add(rel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Added = append(s.Added, rel)
+}
+
+// finish writes the snapshot and prunes old ones. Snapshots of a first
+// install, without changes, or with files that couldn't be saved are
+// discarded.
+func (s *backupSnapshot) finish(p *product) {
+	if s.broken || len(s.Saved) == 0 && (len(s.Added) == 0 || len(s.Files) == 0) {
+		if s.broken {
+			logger.Warn("discarding incomplete backup", "product", p.config.ID, "version", s.Version)
+		}
+		os.RemoveAll(s.dir)
+	} else {
+		data, err := json.Marshal(s)
+		if err == nil {
+			err = os.MkdirAll(s.dir, os.ModePerm)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(s.dir, snapshotFile), data, 0644)
+		}
+		if err != nil {
+			logger.Error("writing backup failed", "product", p.config.ID, "err", err)
+			os.RemoveAll(s.dir)
+		} else {
+			logger.Info("backed up previous version", "product", p.config.ID, "version", s.Version, "files", len(s.Saved), "bytes", s.Size)
+		}
+	}
+	p.pruneBackups()
+}
+
+// snapshots returns the complete snapshots, newest first.
+func (p *product) snapshots() []*backupSnapshot {
+	entries, err := os.ReadDir(p.path(backupDir))
+	if err != nil {
+		return nil
+	}
+	var snapshots []*backupSnapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(p.path(backupDir), entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+		if err != nil {
+			continue
+		}
+		snapshot := &backupSnapshot{dir: dir}
+		if err := json.Unmarshal(data, snapshot); err != nil {
+			logger.Warn("reading backup failed", "product", p.config.ID, "dir", dir, "err", err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+	return snapshots
+}
+
+// pruneBackups removes the oldest snapshots until the configured number of
+// versions and disk usage are respected, and whatever an interrupted update
+// left behind. It must only run while the product's updating lock is held.
+func (p *product) pruneBackups() {
+	entries, _ := os.ReadDir(p.path(backupDir))
+	for _, entry := range entries {
+		dir := filepath.Join(p.path(backupDir), entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, snapshotFile)); os.IsNotExist(err) {
+			os.RemoveAll(dir)
+		}
+	}
+
+	maxVersions, maxSize := backupSettings()
+	var used int64
+	for i, snapshot := range p.snapshots() {
+		used += snapshot.Size
+		if i < maxVersions && used <= maxSize {
+			continue
+		}
+		logger.Info("pruning backup", "product", p.config.ID, "version", snapshot.Version, "bytes", snapshot.Size)
+		if err := os.RemoveAll(snapshot.dir); err != nil {
+			logger.Warn("pruning backup failed", "product", p.config.ID, "dir", snapshot.dir, "err", err)
+		}
+	}
+}
+
+// PreviousVersion returns the version RollbackToPrevious would restore for
+// the selected product.
+func (a *App) PreviousVersion() PreviousVersion {
+	p := a.current()
+	if p.rootDir() == "" {
+		return PreviousVersion{}
+	}
+	snapshots := p.snapshots()
+	if len(snapshots) == 0 || p.updateForced(snapshots[0].Version) {
+		return PreviousVersion{}
+	}
+	return PreviousVersion{Available: true, Version: snapshots[0].Version, Created: snapshots[0].Created}
+}
+
+// RollbackToPrevious restores the newest backup of the selected product and
+// returns its version. Until the backend publishes a different release, the
+// release that was rolled back is not downloaded again. Rolling back is
+// refused while the backend requires a newer release.
+func (a *App) RollbackToPrevious() (string, error) {
+	p := a.current()
+	if p.rootDir() == "" {
+		return "", errNoInstallDir
+	}
+	if !p.updating.TryLock() {
+		return "", errUpdateInProgress
+	}
+	defer p.updating.Unlock()
+
+	p.setStatus("rollingBack")
+	version, err := p.rollback()
+	if err != nil {
+		p.setError(err)
+		logger.Error("rollback failed", "product", p.config.ID, "err", err)
+		return "", err
+	}
+	p.setStatus("ready")
+	return version, nil
+}
+
+// rename is one step of a rollback, kept so that it can be reverted.
+type rename struct{ from, to string }
+
+// rollback restores the newest snapshot. Every saved file is verified before
+// anything is touched. The files being replaced are moved aside first and
+// put back if any step fails, so the install is either fully restored or
+// left as it was.
+func (p *product) rollback() (string, error) {
+	snapshots := p.snapshots()
+	if len(snapshots) == 0 {
+		return "", newLocalError("error.noPreviousVersion")
+	}
+	snapshot := snapshots[0]
+	if p.updateForced(snapshot.Version) {
+		return "", newLocalError("error.rollbackRequired")
+	}
+
+	for _, file := range snapshot.Saved {
+		if !updater.SafePath(file.Path) {
+			return "", fmt.Errorf("invalid path in backup: %q", file.Path)
+		}
+		hash, size, err := updater.HashFile(snapshot.savedPath(file.Path))
+		if err != nil || hash != file.Hash || size != file.Size {
+			return "", newLocalError("error.backupDamaged", "path", file.Path)
+		}
+	}
+
+	undo := filepath.Join(snapshot.dir, "undo")
+	var done []rename
+	move := func(from, to string) error {
+		if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, rename{from, to})
+		return nil
+	}
+	restore := func(err error) (string, error) {
+		for i := len(done) - 1; i >= 0; i-- {
+			if rerr := os.Rename(done[i].to, done[i].from); rerr != nil {
+				logger.Error("reverting rollback failed", "product", p.config.ID, "path", done[i].from, "err", rerr)
+			}
+		}
+		os.RemoveAll(undo)
+		return "", err
+	}
+
+	moveAside := func(rel string) error {
+		err := move(p.path(rel), filepath.Join(undo, filepath.FromSlash(rel)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, rel := range snapshot.Added {
+		if !updater.SafePath(rel) {
+			continue
+		}
+		if err := moveAside(rel); err != nil {
+			return restore(err)
+		}
+	}
+	for _, file := range snapshot.Saved {
+		if err := moveAside(file.Path); err != nil {
+			return restore(err)
+		}
+		if err := move(snapshot.savedPath(file.Path), p.path(file.Path)); err != nil {
+			return restore(err)
+		}
+	}
+
+	// Skip the release that was rolled back until the backend publishes
+	// another one.
+	meta := snapshot.Meta
+	if data, err := os.ReadFile(p.path(".downloadmeta")); err == nil {
+		var current MetaData
+		json.Unmarshal(data, &current)
+		meta.Skip = current.Hash
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err == nil {
+		err = os.WriteFile(p.path(".downloadmeta"), metaJSON, 0644)
+	}
+	if err != nil {
+		return restore(err)
+	}
+	p.setPinned(meta.Pinned)
+	if snapshot.Files != nil {
+		err = p.saveInstalledFiles(snapshot.Files)
+	} else {
+		err = os.Remove(p.path(installedFilesFile))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		logger.Warn("restoring installed manifest failed", "product", p.config.ID, "err", err)
+	}
+
+	if err := os.RemoveAll(snapshot.dir); err != nil {
+		logger.Warn("removing restored backup failed", "product", p.config.ID, "err", err)
+	}
+	logger.Info("rolled back to previous version", "product", p.config.ID, "version", snapshot.Version, "files", len(snapshot.Saved), "removed", len(snapshot.Added))
+
+	if snapshot.Version != "" && snapshot.Version != p.version() {
+		p.setVersion(snapshot.Version)
+		p.emit("versionUpdate", snapshot.Version)
+	}
+	return snapshot.Version, nil
+}
+
+// copyPath copies the file at src to dst.
+func copyPath(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
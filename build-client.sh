@@ -455,6 +455,13 @@ build_platform() {
         wails_cmd="$wails_cmd --debug"
     fi
 
+    # The whole config is baked in as base64, so that settings without an
+    # ldflags variable of their own reach the client too
+    local baked_config=""
+    if [[ -f "$config_file" ]]; then
+        baked_config=$(base64 < "$config_file" | tr -d '\n')
+    fi
+
     ldflags="-X 'main.DefaultBackend=${BACKEND}' \
          -X 'main.DefaultExecutable=${EXECUTABLE}' \
          -X 'main.DefaultPalette=${COLOR_PALETTE}' \
@@ -463,6 +470,7 @@ build_platform() {
          -X 'main.DefaultDesc=${DESCRIPTION}' \
          -X 'main.DefaultTitle=${TITLE}' \
          -X 'main.DefaultDisplay=${DISPLAY_NAME}' \
+         -X 'main.BakedConfig=${baked_config}' \
          -X 'main.Built=true'"
 
     wails_cmd="$wails_cmd --platform $platform --o $output_file --ldflags \"$ldflags\""
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ppatcher/updater"
+)
+
+// bundleFormat is the newest .ppatch layout the patcher understands.
+const bundleFormat = 1
+
+// maxBundleManifestSize bounds the manifest read before its signature has
+// been checked.
+const maxBundleManifestSize = 64 << 20
+
+// bundleManifest is the signed manifest.json of a .ppatch bundle, as
+// written by the ppbundle tool.
+type bundleManifest struct {
+	Format      int                  `json:"format"`
+	Product     string               `json:"product"`
+	Version     string               `json:"version"`
+	BaseVersion string               `json:"baseVersion"`
+	Delta       bool                 `json:"delta"`
+	Created     time.Time            `json:"created"`
+	Files       []bundleManifestFile `json:"files"`
+}
+
+type bundleManifestFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	SHA256   string `json:"sha256"`
+	Included bool   `json:"included"`
+}
+
+func (f bundleManifestFile) meta() MetaForFile {
+	return MetaForFile{Hash: f.Hash, Path: f.Path, Size: f.Size}
+}
+
+// ImportBundle asks the player for a .ppatch file and installs it into the
+// selected product without contacting the backend. It returns the version
+// of the imported release, or "" if the dialog was cancelled.
+func (a *App) ImportBundle() (string, error) {
+	p := a.current()
+	if p.rootDir() == "" {
+		return "", errNoInstallDir
+	}
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   T("bundle.import"),
+		Filters: []runtime.FileFilter{{DisplayName: T("bundle.filter"), Pattern: "*.ppatch"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	if !p.updating.TryLock() {
+		return "", errUpdateInProgress
+	}
+	defer p.updating.Unlock()
+
+	version, err := p.importBundle(path)
+	if err != nil {
+		p.setError(err)
+		logger.Error("importing bundle failed", "product", p.config.ID, "path", path, "err", err)
+		return "", err
+	}
+	return version, nil
+}
+
+// readBundleEntry reads the next tar entry, which must be called name.
+func readBundleEntry(tr *tar.Reader, name string) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, wrapLocalError(err, "error.bundleInvalid")
+	}
+	if header.Name != name || header.Size > maxBundleManifestSize {
+		return nil, wrapLocalError(fmt.Errorf("expected %s", name), "error.bundleInvalid")
+	}
+	return io.ReadAll(tr)
+}
+
+// verifyBundleManifest checks the manifest signature against the product's
+// bundle key and that the bundle is meant for this product.
+func (p *product) verifyBundleManifest(manifestJSON, signature []byte) (*bundleManifest, error) {
+	if p.config.BundlePublicKey == "" {
+		return nil, newLocalError("error.bundlesDisabled")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(p.config.BundlePublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid bundlePublicKey in the patcher configuration")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), manifestJSON, signature) {
+		return nil, newLocalError("error.bundleSignature")
+	}
+
+	var manifest bundleManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Format > bundleFormat {
+		return nil, newLocalError("error.bundleNewer")
+	}
+	if manifest.Product != "" && manifest.Product != p.config.ID {
+		return nil, newLocalError("error.bundleProduct", "product", manifest.Product)
+	}
+	for _, file := range manifest.Files {
+		if !updater.SafePath(file.Path) {
+			return nil, fmt.Errorf("invalid path in bundle: %q", file.Path)
+		}
+	}
+	return &manifest, nil
+}
+
+// importBundle verifies and installs a .ppatch bundle. Files a delta bundle
+// doesn't carry must already be installed with the expected hash; this is
+// checked before anything is written. Every payload goes through the same
+// verified, atomic Install as network downloads, and replaced and dropped
+// files are backed up and cleaned up as by an update.
+func (p *product) importBundle(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	manifestJSON, err := readBundleEntry(tr, "manifest.json")
+	if err != nil {
+		return "", err
+	}
+	signature, err := readBundleEntry(tr, "manifest.sig")
+	if err != nil {
+		return "", err
+	}
+	manifest, err := p.verifyBundleManifest(manifestJSON, signature)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("importing bundle", "product", p.config.ID, "path", path, "version", manifest.Version, "delta", manifest.Delta)
+
+	p.setStatus("importing")
+	report := newUpdateReport(p, len(manifest.Files))
+
+	files := map[string]bundleManifestFile{}
+	var totalSize, payloadSize int64
+	for _, file := range manifest.Files {
+		totalSize += file.Size
+		if file.Included {
+			files[file.Path] = file
+			payloadSize += file.Size
+			continue
+		}
+		hash, _, err := updater.HashFile(p.path(file.Path))
+		if err != nil || hash != file.Hash {
+			if manifest.BaseVersion != "" {
+				return "", wrapLocalError(fmt.Errorf("%s differs", file.Path), "error.bundleRequiresVersion", "version", manifest.BaseVersion)
+			}
+			return "", wrapLocalError(fmt.Errorf("%s differs", file.Path), "error.bundleRequiresPrevious")
+		}
+		report.skip()
+	}
+
+	previous := p.loadInstalledFiles()
+	backup := p.beginBackup(previous)
+
+	client := p.client()
+	var installed int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading bundle: %w", err)
+		}
+		rel, ok := strings.CutPrefix(header.Name, "files/")
+		file, listed := files[rel]
+		if !ok || !listed {
+			return "", fmt.Errorf("bundle contains unexpected entry %q", header.Name)
+		}
+		p.setCurrentFile(file.Path, file.Size)
+		if backup != nil {
+			hash, size, err := updater.HashFile(p.path(file.Path))
+			if os.IsNotExist(err) {
+				backup.add(file.Path)
+			} else if err == nil && hash != file.Hash {
+				backup.save(p, file.Path, hash, size)
+			}
+		}
+		if err := client.Install(file.meta(), file.SHA256, tr); err != nil {
+			report.fail(file.Path, err)
+			p.finishReport(report)
+			return "", err
+		}
+		delete(files, rel)
+		report.download(file.Size)
+		installed += file.Size
+		if payloadSize > 0 {
+			p.setProgress(float64(installed) / float64(payloadSize))
+		}
+	}
+	if len(files) > 0 {
+		return "", newLocalError("error.bundleIncomplete")
+	}
+
+	metaFiles := make([]MetaForFile, len(manifest.Files))
+	for i, file := range manifest.Files {
+		metaFiles[i] = file.meta()
+	}
+	metaJSON, err := json.Marshal(MetaData{Hash: updater.OverallHash(metaFiles), TotalSize: totalSize, Version: manifest.Version})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p.path(".downloadmeta"), metaJSON, 0644); err != nil {
+		return "", err
+	}
+
+	p.removeDroppedFiles(previous, metaFiles, backup)
+	if err := p.saveInstalledFiles(metaFiles); err != nil {
+		logger.Warn("writing installed manifest failed", "product", p.config.ID, "err", err)
+	}
+	if backup != nil {
+		backup.finish(p)
+	}
+	p.setPinned("")
+
+	p.finishReport(report)
+	if manifest.Version != "" && manifest.Version != p.version() {
+		p.setVersion(manifest.Version)
+		p.emit("versionUpdate", manifest.Version)
+	}
+	p.setOutdated(false)
+	p.setStatus("ready")
+	return manifest.Version, nil
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"embed"
-	"encoding/json"
-	"os"
 )
 
 //go:embed config.json
@@ -22,114 +20,72 @@ var (
 	DefaultDesc       = "Keep your files up to date"
 	DefaultTitle      = "ppatcher"
 	DefaultDisplay    = "PPatcher"
+	// BakedConfig is the whole config.json, base64 encoded. Release builds
+	// use it instead of the embedded file.
+	BakedConfig = ""
 )
 
-func InitConfig() {
-	if Built == "true" {
-		BuildConfig = &Config{
-			Backend:      DefaultBackend,
-			Executable:   DefaultExecutable,
-			ColorPalette: DefaultPalette,
-			Mode:         DefaultMode,
-			Version:      DefaultVersion,
-			Description:  DefaultDesc,
-			Title:        DefaultTitle,
-			DisplayName:  DefaultDisplay,
-		}
-		return
-	}
-
-	configFile := "config.json"
-	if envConfig := os.Getenv("CONFIG_FILE"); envConfig != "" {
-		configFile = envConfig
-	}
-
-	data, err := buildConfig.ReadFile(configFile)
-	if err != nil {
-		BuildConfig = &Config{
-			Backend:      DefaultBackend,
-			Executable:   DefaultExecutable,
-			ColorPalette: DefaultPalette,
-			Mode:         DefaultMode,
-			Version:      DefaultVersion,
-			Description:  DefaultDesc,
-			Title:        DefaultTitle,
-			DisplayName:  DefaultDisplay,
-		}
-		return
-	}
-	BuildConfig = MarshalConfig(data)
-
-	// Env overrides
-	if envBackend := os.Getenv("BACKEND"); envBackend != "" {
-		BuildConfig.Backend = envBackend
-	}
-	if envExecutable := os.Getenv("EXECUTABLE"); envExecutable != "" {
-		BuildConfig.Executable = envExecutable
-	}
-	if envColorPalette := os.Getenv("COLOR_PALETTE"); envColorPalette != "" {
-		BuildConfig.ColorPalette = envColorPalette
-	}
-	if envMode := os.Getenv("MODE"); envMode != "" {
-		BuildConfig.Mode = envMode
-	}
-	if envVersion := os.Getenv("VERSION"); envVersion != "" {
-		BuildConfig.Version = envVersion
-	}
-	if envDescription := os.Getenv("DESCRIPTION"); envDescription != "" {
-		BuildConfig.Description = envDescription
-	}
-	if envTitle := os.Getenv("TITLE"); envTitle != "" {
-		BuildConfig.Title = envTitle
-	}
-	if envDisplayName := os.Getenv("DISPLAY_NAME"); envDisplayName != "" {
-		BuildConfig.DisplayName = envDisplayName
-	}
-}
-
 type Config struct {
 	Backend      string   `json:"backend"`
 	FallbackURLs []string `json:"fallbackUrls"`
 	Executable   string   `json:"executable"`
-	ColorPalette string `json:"colorPalette"`
-	Mode         string `json:"mode"`
-	Version      string `json:"version"`
-	Description  string `json:"description"`
-	Title        string `json:"title"`
-	DisplayName  string `json:"displayName"`
-	Logo         string `json:"logo"`
-	Icon         string `json:"icon"`
+	ColorPalette string   `json:"colorPalette"`
+	Mode         string   `json:"mode"`
+	Version      string   `json:"version"`
+	Description  string   `json:"description"`
+	Title        string   `json:"title"`
+	DisplayName  string   `json:"displayName"`
+	Logo         string   `json:"logo"`
+	Icon         string   `json:"icon"`
+	InstallDir   string   `json:"installDir"`
+	// LaunchProfiles and Products are optional; see ProductConfig.
+	LaunchProfiles []LaunchProfile `json:"launchProfiles"`
+	Products       []ProductConfig `json:"products"`
+	// Network configures timeouts, proxy and TLS trust for all requests.
+	Network NetworkConfig `json:"network"`
+	// BundlePublicKey is the base64 ed25519 key offline update bundles must
+	// be signed with. Bundles can't be imported without it.
+	BundlePublicKey string `json:"bundlePublicKey"`
+	// UpdateCheck configures checks while the patcher stays open.
+	UpdateCheck UpdateCheckConfig `json:"updateCheck"`
+	// Backup keeps the previous version for RollbackToPrevious.
+	Backup BackupConfig `json:"backup"`
+	// Theme overrides colours of the colorPalette and sets the background
+	// image and font.
+	Theme Theme `json:"theme"`
+	// Language is the UI language until the player picks one; empty uses
+	// the OS locale.
+	Language string `json:"language"`
+	// Translations adds or replaces messages of the built-in catalogues, by
+	// language code and message key.
+	Translations map[string]map[string]string `json:"translations"`
+	// Telemetry offers players to send anonymous update reports.
+	Telemetry TelemetryConfig `json:"telemetry"`
+}
+
+// ProductConfig describes one game or application managed by the patcher.
+// A config without products manages a single product built from the
+// top-level fields.
+type ProductConfig struct {
+	ID             string          `json:"id"`
+	DisplayName    string          `json:"displayName"`
+	Description    string          `json:"description"`
+	Version        string          `json:"version"`
+	Backend        string          `json:"backend"`
+	FallbackURLs   []string        `json:"fallbackUrls"`
+	InstallDir     string          `json:"installDir"`
+	Executable     string          `json:"executable"`
+	LaunchProfiles []LaunchProfile `json:"launchProfiles"`
+	Logo           string          `json:"logo"`
+	// BundlePublicKey defaults to the top-level bundlePublicKey.
+	BundlePublicKey string `json:"bundlePublicKey"`
 }
 
-func MarshalConfig(data []byte) *Config {
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		panic(err)
-	}
-	// Fill in defaults if missing
-	if config.Backend == "" {
-		config.Backend = DefaultBackend
-	}
-	if config.Executable == "" {
-		config.Executable = DefaultExecutable
-	}
-	if config.ColorPalette == "" {
-		config.ColorPalette = DefaultPalette
-	}
-	if config.Mode == "" {
-		config.Mode = DefaultMode
-	}
-	if config.Version == "" {
-		config.Version = DefaultVersion
-	}
-	if config.Description == "" {
-		config.Description = DefaultDesc
-	}
-	if config.Title == "" {
-		config.Title = DefaultTitle
-	}
-	if config.DisplayName == "" {
-		config.DisplayName = DefaultDisplay
-	}
-	return &config
+// LaunchProfile is a named way of starting a product, such as "Safe mode"
+// or a dedicated server. Executable defaults to the product's executable.
+type LaunchProfile struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Executable string   `json:"executable"`
+	Args       []string `json:"args"`
 }
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// overrideConfigFile is read from the patcher's directory and overrides
+	// the baked config.
+	overrideConfigFile = "patcher.config.json"
+	// envPrefix prefixes the environment variable of every setting, such as
+	// PPATCHER_BACKEND or PPATCHER_NETWORK_PROXY.
+	envPrefix = "PPATCHER_"
+)
+
+// buildOnlyKeys are config keys used by the build scripts only.
+var buildOnlyKeys = map[string]bool{"outputName": true}
+
+// legacyEnv are the unprefixed variables that override the embedded config
+// in development builds.
+var legacyEnv = map[string]string{
+	"BACKEND":       "backend",
+	"EXECUTABLE":    "executable",
+	"COLOR_PALETTE": "colorPalette",
+	"MODE":          "mode",
+	"VERSION":       "version",
+	"DESCRIPTION":   "description",
+	"TITLE":         "title",
+	"DISPLAY_NAME":  "displayName",
+	"INSTALL_DIR":   "installDir",
+}
+
+// ConfigReport tells where each setting came from and what was wrong with
+// the configuration. Sources maps dotted keys such as "network.proxy" to
+// the layer that set them.
+type ConfigReport struct {
+	Sources  map[string]string `json:"sources"`
+	Errors   []string          `json:"errors"`
+	Warnings []string          `json:"warnings"`
+}
+
+var configReport = ConfigReport{Sources: map[string]string{}}
+
+// InitConfig builds BuildConfig from its layers, each overriding the ones
+// before: the compiled defaults, the baked config, patcher.config.json next
+// to the executable, PPATCHER_* environment variables and the --config and
+// --set command line flags. Invalid layers and values are skipped and
+// recorded in the config report instead of stopping the patcher.
+func InitConfig() {
+	loader := &configLoader{tree: map[string]interface{}{}, report: &configReport}
+	loader.mergeStruct(defaultConfig(), "default")
+
+	if Built == "true" {
+		if BakedConfig != "" {
+			data, err := base64.StdEncoding.DecodeString(BakedConfig)
+			if err != nil {
+				loader.errorf("baked config: %v", err)
+			} else {
+				loader.mergeJSON(data, "baked")
+			}
+		}
+	} else {
+		configFile := "config.json"
+		if envConfig := os.Getenv("CONFIG_FILE"); envConfig != "" {
+			configFile = envConfig
+		}
+		if data, err := buildConfig.ReadFile(configFile); err == nil {
+			loader.mergeJSON(data, "embedded:"+configFile)
+		} else {
+			loader.warnf("embedded config %s not found, using defaults", configFile)
+		}
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exe), overrideConfigFile)
+		if data, err := os.ReadFile(path); err == nil {
+			loader.mergeJSON(data, "file:"+path)
+		} else if !os.IsNotExist(err) {
+			loader.errorf("%s: %v", path, err)
+		}
+	}
+
+	env := map[string]interface{}{}
+	if Built != "true" {
+		for name, key := range legacyEnv {
+			if value := os.Getenv(name); value != "" {
+				loader.setFlat(env, key, value, "env:"+name)
+			}
+		}
+	}
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		name := envName(key)
+		if value, ok := os.LookupEnv(name); ok {
+			loader.setFlat(env, key, value, "env:"+name)
+		}
+	}
+	loader.mergeFlat(env, "env")
+
+	configPath, settings := parseConfigFlags(os.Args[1:])
+	if configPath != "" {
+		if data, err := os.ReadFile(configPath); err != nil {
+			loader.errorf("--config: %v", err)
+		} else {
+			loader.mergeJSON(data, "flag:--config "+configPath)
+		}
+	}
+	flags := map[string]interface{}{}
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			loader.errorf("--set %s: expected key=value", setting)
+			continue
+		}
+		loader.setFlat(flags, strings.TrimSpace(key), value, "flag:--set")
+	}
+	loader.mergeFlat(flags, "flag")
+
+	BuildConfig = loader.config()
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Backend:      DefaultBackend,
+		Executable:   DefaultExecutable,
+		ColorPalette: DefaultPalette,
+		Mode:         DefaultMode,
+		Version:      DefaultVersion,
+		Description:  DefaultDesc,
+		Title:        DefaultTitle,
+		DisplayName:  DefaultDisplay,
+	}
+}
+
+// configLoader merges config layers as JSON trees, checking every value
+// against the Config schema before it is taken.
+type configLoader struct {
+	tree   map[string]interface{}
+	report *ConfigReport
+	// sources collects the sources of values set from flat keys, which
+	// are merged as one layer.
+	sources map[string]string
+}
+
+func (l *configLoader) errorf(format string, args ...interface{}) {
+	l.report.Errors = append(l.report.Errors, fmt.Sprintf(format, args...))
+}
+
+func (l *configLoader) warnf(format string, args ...interface{}) {
+	l.report.Warnings = append(l.report.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (l *configLoader) mergeStruct(config *Config, source string) {
+	data, _ := json.Marshal(config)
+	l.mergeJSON(data, source)
+}
+
+// mergeJSON merges a JSON config layer. A layer that isn't valid JSON is
+// skipped as a whole.
+func (l *configLoader) mergeJSON(data []byte, source string) {
+	var layer map[string]interface{}
+	if err := json.Unmarshal(data, &layer); err != nil {
+		if syntax, ok := err.(*json.SyntaxError); ok {
+			line := 1 + strings.Count(string(data[:syntax.Offset]), "\n")
+			l.errorf("%s: invalid JSON on line %d: %v", source, line, err)
+		} else {
+			l.errorf("%s: %v", source, err)
+		}
+		return
+	}
+	l.merge(layer, source)
+}
+
+func (l *configLoader) merge(layer map[string]interface{}, source string) {
+	l.mergeInto(l.tree, layer, reflect.TypeOf(Config{}), "", source)
+}
+
+// mergeFlat merges a layer built with setFlat, attributing each value to
+// the variable or flag that set it.
+func (l *configLoader) mergeFlat(layer map[string]interface{}, source string) {
+	l.merge(layer, source)
+	l.sources = nil
+}
+
+// mergeInto merges src into dst. Objects are merged key by key, any other
+// value replaces the one of lower layers.
+func (l *configLoader) mergeInto(dst, src map[string]interface{}, t reflect.Type, prefix, source string) {
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := src[key]
+		path := prefix + key
+		field, ok := jsonField(t, key)
+		if !ok {
+			if prefix != "" || !buildOnlyKeys[key] {
+				l.warnf("%s: unknown setting %q", source, path)
+			}
+			continue
+		}
+		if problem := checkValue(path, value, field.Type); problem != "" {
+			l.errorf("%s: %s", source, problem)
+			continue
+		}
+
+		if child, ok := value.(map[string]interface{}); ok && field.Type.Kind() == reflect.Struct {
+			existing, _ := dst[key].(map[string]interface{})
+			if existing == nil {
+				existing = map[string]interface{}{}
+				dst[key] = existing
+			}
+			l.mergeInto(existing, child, field.Type, path+".", source)
+			continue
+		}
+		dst[key] = value
+		l.report.Sources[path] = source
+		if flatSource, ok := l.sources[path]; ok {
+			l.report.Sources[path] = flatSource
+		}
+	}
+}
+
+// setFlat sets the dotted key in a layer from a string, converting it to
+// the setting's type. Lists take comma separated values or a JSON array;
+// objects and lists of objects take JSON.
+func (l *configLoader) setFlat(layer map[string]interface{}, key, raw, source string) {
+	t := reflect.TypeOf(Config{})
+	parts := strings.Split(key, ".")
+	node := layer
+	for i, part := range parts {
+		field, ok := jsonField(t, part)
+		if !ok {
+			l.warnf("%s: unknown setting %q", source, key)
+			return
+		}
+		t = field.Type
+		if i == len(parts)-1 {
+			break
+		}
+		if t.Kind() != reflect.Struct {
+			l.warnf("%s: unknown setting %q", source, key)
+			return
+		}
+		child, _ := node[part].(map[string]interface{})
+		if child == nil {
+			child = map[string]interface{}{}
+			node[part] = child
+		}
+		node = child
+	}
+
+	var value interface{}
+	var err error
+	switch {
+	case t.Kind() == reflect.String:
+		value = raw
+	case t.Kind() == reflect.Bool:
+		value, err = strconv.ParseBool(strings.TrimSpace(raw))
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		value = float64(n)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "["):
+		items := []interface{}{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+	default:
+		err = json.Unmarshal([]byte(raw), &value)
+	}
+	if err != nil {
+		l.errorf("%s: invalid value for %s: %v", source, key, err)
+		return
+	}
+	node[parts[len(parts)-1]] = value
+	if l.sources == nil {
+		l.sources = map[string]string{}
+	}
+	l.sources[key] = source
+}
+
+// config decodes the merged tree, fills in the defaults of empty settings
+// and validates the result.
+func (l *configLoader) config() *Config {
+	data, _ := json.Marshal(l.tree)
+	config := defaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		l.errorf("config: %v", err)
+		config = defaultConfig()
+	}
+
+	// Empty values fall back to the defaults, as in earlier versions.
+	fill := func(key string, value *string, fallback string) {
+		if *value == "" {
+			*value = fallback
+			l.report.Sources[key] = "default"
+		}
+	}
+	fill("backend", &config.Backend, DefaultBackend)
+	fill("executable", &config.Executable, DefaultExecutable)
+	fill("colorPalette", &config.ColorPalette, DefaultPalette)
+	fill("mode", &config.Mode, DefaultMode)
+	fill("version", &config.Version, DefaultVersion)
+	fill("description", &config.Description, DefaultDesc)
+	fill("title", &config.Title, DefaultTitle)
+	fill("displayName", &config.DisplayName, DefaultDisplay)
+
+	l.validate(config)
+	return config
+}
+
+// validate checks values that are well-typed but can't work. Values the
+// frontend can't display are reset to their defaults.
+func (l *configLoader) validate(config *Config) {
+	checkURL := func(key, value string) {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			l.errorf("%s (%s): %q is not an http or https URL", key, l.report.Sources[key], value)
+		}
+	}
+	checkDuration := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			l.errorf("%s (%s): %q is not a duration such as \"30s\" or \"2h\"", key, l.report.Sources[key], value)
+		}
+	}
+
+	checkURL("backend", config.Backend)
+	for i, fallback := range config.FallbackURLs {
+		checkURL(fmt.Sprintf("fallbackUrls[%d]", i), fallback)
+	}
+
+	if _, ok := builtinThemes[config.ColorPalette]; !ok {
+		l.errorf("colorPalette (%s): unknown palette %q, using %q", l.report.Sources["colorPalette"], config.ColorPalette, DefaultPalette)
+		config.ColorPalette = DefaultPalette
+		if _, ok := builtinThemes[config.ColorPalette]; !ok {
+			config.ColorPalette = "neutral"
+		}
+	}
+	l.resolveTheme(config)
+	if config.Language != "" && !knownLanguage(config, config.Language) {
+		l.warnf("language (%s): no messages for %q, using the OS locale", l.report.Sources["language"], config.Language)
+		config.Language = ""
+	}
+	if config.Mode != "production" && config.Mode != "dev" {
+		l.warnf("mode (%s): unknown mode %q", l.report.Sources["mode"], config.Mode)
+	}
+
+	checkDuration("network.connectTimeout", config.Network.ConnectTimeout)
+	checkDuration("network.idleTimeout", config.Network.IdleTimeout)
+	checkDuration("network.requestTimeout", config.Network.RequestTimeout)
+	checkDuration("updateCheck.interval", config.UpdateCheck.Interval)
+	checkDuration("updateCheck.maxBackoff", config.UpdateCheck.MaxBackoff)
+	if policy := config.UpdateCheck.Policy; policy != "" && policy != updatePolicyPrompt && policy != updatePolicyAuto {
+		l.errorf("updateCheck.policy (%s): %q is not \"prompt\" or \"auto\"", l.report.Sources["updateCheck.policy"], policy)
+	}
+	if config.Backup.MaxVersions < 0 || config.Backup.MaxSizeMB < 0 {
+		l.errorf("backup: limits must not be negative")
+	}
+	if network := config.Network; network.MinDownloads < 0 || network.MaxDownloads < 0 {
+		l.errorf("network: download limits must not be negative")
+	} else if network.MaxDownloads > 0 && network.MinDownloads > network.MaxDownloads {
+		l.warnf("network: minDownloads (%d) is above maxDownloads (%d), using %d", network.MinDownloads, network.MaxDownloads, network.MaxDownloads)
+	}
+
+	checkKey := func(key, value string) {
+		if value == "" {
+			return
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(decoded) != 32 {
+			l.errorf("%s: not a base64 ed25519 public key", key)
+		}
+	}
+	checkKey("bundlePublicKey", config.BundlePublicKey)
+
+	ids := map[string]bool{}
+	for i, product := range config.Products {
+		key := fmt.Sprintf("products[%d]", i)
+		if product.ID == "" {
+			l.errorf("%s: missing id", key)
+		} else if ids[product.ID] {
+			l.errorf("%s: duplicate id %q", key, product.ID)
+		}
+		ids[product.ID] = true
+		if product.Backend != "" {
+			checkURL(key+".backend", product.Backend)
+		}
+		for j, fallback := range product.FallbackURLs {
+			checkURL(fmt.Sprintf("%s.fallbackUrls[%d]", key, j), fallback)
+		}
+		checkKey(key+".bundlePublicKey", product.BundlePublicKey)
+	}
+}
+
+// logConfigReport logs the problems found while loading the config. It
+// runs once logging is set up, which itself depends on the config.
+func logConfigReport() {
+	for _, warning := range configReport.Warnings {
+		logger.Warn("config warning", "problem", warning)
+	}
+	for _, problem := range configReport.Errors {
+		logger.Error("config error", "problem", problem)
+	}
+	logger.Debug("config sources", "sources", configReport.Sources)
+}
+
+// ConfigReport returns where each setting came from and the problems found
+// in the configuration.
+func (a *App) ConfigReport() ConfigReport {
+	return configReport
+}
+
+// jsonField returns the field of struct type t with the given JSON name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.IsExported() && tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// checkValue checks a decoded JSON value against a Go type and describes
+// the first mismatch. Unknown keys of nested objects are left to the JSON
+// decoder, which ignores them.
+func checkValue(path string, value interface{}, t reflect.Type) string {
+	if value == nil {
+		return ""
+	}
+	mismatch := func(want string) string {
+		return fmt.Sprintf("%s must be %s, got %s", path, want, jsonKind(value))
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return mismatch("a string")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return mismatch("true or false")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return mismatch("a whole number")
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch("a list")
+		}
+		for i, item := range items {
+			if problem := checkValue(fmt.Sprintf("%s[%d]", path, i), item, t.Elem()); problem != "" {
+				return problem
+			}
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch("an object")
+		}
+		for key, item := range object {
+			if problem := checkValue(path+"."+key, item, t.Elem()); problem != "" {
+				return problem
+			}
+		}
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch("an object")
+		}
+		for key, item := range object {
+			if field, ok := jsonField(t, key); ok {
+				if problem := checkValue(path+"."+key, item, field.Type); problem != "" {
+					return problem
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return "null"
+}
+
+// configKeys returns the dotted keys of every setting. Nested objects such
+// as network are expanded; lists are single settings.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(field.Type, prefix+tag+".")...)
+			continue
+		}
+		keys = append(keys, prefix+tag)
+	}
+	return keys
+}
+
+// envName returns the environment variable of a setting, for example
+// PPATCHER_UPDATE_CHECK_MAX_BACKOFF for updateCheck.maxBackoff.
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, part := range strings.Split(key, ".") {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		runes := []rune(part)
+		for j, r := range runes {
+			if j > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[j-1]) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// parseConfigFlags picks --config and --set out of the command line. Other
+// arguments are left alone, since the platform and the Wails runtime add
+// their own.
+func parseConfigFlags(args []string) (configPath string, settings []string) {
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != "config" && name != "set") {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		if name == "config" {
+			configPath = value
+		} else {
+			settings = append(settings, value)
+		}
+	}
+	return configPath, settings
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	goRunTime "runtime"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// UpdateReport summarises the last verification and update run of a
+// product, for support tickets.
+type UpdateReport struct {
+	Product    string            `json:"product"`
+	Started    time.Time         `json:"started"`
+	Finished   time.Time         `json:"finished"`
+	Checked    int               `json:"checked"`
+	UpToDate   int               `json:"upToDate"`
+	Downloaded int               `json:"downloaded"`
+	Bytes      int64             `json:"bytes"`
+	Retried    int               `json:"retried"`
+	Failed     map[string]string `json:"failed,omitempty"`
+
+	mu sync.Mutex
+}
+
+func newUpdateReport(p *product, checked int) *UpdateReport {
+	return &UpdateReport{Product: p.config.ID, Started: time.Now(), Checked: checked}
+}
+
+func (r *UpdateReport) skip() {
+	r.mu.Lock()
+	r.UpToDate++
+	r.mu.Unlock()
+}
+
+func (r *UpdateReport) download(size int64) {
+	r.mu.Lock()
+	r.Downloaded++
+	r.Bytes += size
+	r.mu.Unlock()
+}
+
+func (r *UpdateReport) retry() {
+	r.mu.Lock()
+	r.Retried++
+	r.mu.Unlock()
+}
+
+func (r *UpdateReport) fail(path string, err error) {
+	r.mu.Lock()
+	if r.Failed == nil {
+		r.Failed = map[string]string{}
+	}
+	r.Failed[path] = err.Error()
+	r.mu.Unlock()
+}
+
+// finishReport records report as the product's last verification report
+// and logs its summary.
+func (p *product) finishReport(report *UpdateReport) {
+	report.mu.Lock()
+	report.Finished = time.Now()
+	report.mu.Unlock()
+
+	logger.Info("update finished",
+		"product", p.config.ID,
+		"checked", report.Checked,
+		"upToDate", report.UpToDate,
+		"downloaded", report.Downloaded,
+		"failed", len(report.Failed),
+		"duration", report.Finished.Sub(report.Started).String(),
+	)
+	p.mu.Lock()
+	p.report = report
+	p.mu.Unlock()
+}
+
+// lastReport returns the result of the last update run, or nil.
+func (p *product) lastReport() *UpdateReport {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.report
+}
+
+// redactedKeys are config key fragments whose values are never exported.
+var redactedKeys = []string{"token", "secret", "password", "license", "key"}
+
+// redactConfig returns BuildConfig as generic JSON with secrets removed and
+// credentials stripped from URLs.
+func redactConfig() (interface{}, error) {
+	data, err := json.Marshal(BuildConfig)
+	if err != nil {
+		return nil, err
+	}
+	var config interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return redactValue("", config), nil
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		lower := strings.ToLower(key)
+		for _, secret := range redactedKeys {
+			if strings.Contains(lower, secret) {
+				return "[redacted]"
+			}
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			u.User = url.User("[redacted]")
+			return u.String()
+		}
+		return v
+	}
+	return value
+}
+
+// systemInfo describes the machine the patcher runs on.
+func systemInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"os":        goRunTime.GOOS,
+		"arch":      goRunTime.GOARCH,
+		"goVersion": goRunTime.Version(),
+		"cpus":      goRunTime.NumCPU(),
+		"version":   BuildConfig.Version,
+		"mode":      BuildConfig.Mode,
+		"time":      time.Now().Format(time.RFC3339),
+	}
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		info["osRelease"] = string(data)
+	}
+	return info
+}
+
+// ExportDiagnostics asks the player where to save a zip with the patcher's
+// logs, its redacted config, each product's install metadata and last
+// verification report, and OS information. It returns the path of the
+// archive, or "" if the dialog was cancelled.
+func (a *App) ExportDiagnostics() (string, error) {
+	defaultDir, _ := os.UserHomeDir()
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:            T("diagnostics.export"),
+		DefaultDirectory: defaultDir,
+		DefaultFilename:  fmt.Sprintf("%s-diagnostics-%s.zip", appDirName(), time.Now().Format("20060102-150405")),
+		Filters:          []runtime.FileFilter{{DisplayName: T("diagnostics.filter"), Pattern: "*.zip"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := a.writeDiagnostics(path); err != nil {
+		logger.Error("exporting diagnostics failed", "path", path, "err", err)
+		return "", err
+	}
+	logger.Info("exported diagnostics", "path", path)
+	return path, nil
+}
+
+func (a *App) writeDiagnostics(path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+
+	writeJSON := func(name string, v interface{}) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	copyFile := func(name, src string) error {
+		in, err := os.Open(src)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		defer in.Close()
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, in)
+		return err
+	}
+
+	if err := writeJSON("system.json", systemInfo()); err != nil {
+		return err
+	}
+	config, err := redactConfig()
+	if err != nil {
+		return err
+	}
+	if err := writeJSON("config.json", config); err != nil {
+		return err
+	}
+	if err := writeJSON("configreport.json", configReport); err != nil {
+		return err
+	}
+
+	if dir, err := logDir(); err == nil {
+		logs, _ := filepath.Glob(filepath.Join(dir, logFileName+"*"))
+		for _, logFile := range logs {
+			if err := copyFile("logs/"+filepath.Base(logFile), logFile); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range a.products {
+		prefix := "products/" + safeDirName(p.config.ID) + "/"
+		if err := writeJSON(prefix+"product.json", p.info()); err != nil {
+			return err
+		}
+		if report := p.lastReport(); report != nil {
+			report.mu.Lock()
+			err := writeJSON(prefix+"report.json", report)
+			report.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		if p.rootDir() == "" {
+			continue
+		}
+		if err := copyFile(prefix+"downloadmeta.json", p.path(".downloadmeta")); err != nil {
+			return err
+		}
+		if err := copyFile(prefix+"groups.json", p.path(groupsFile)); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// eventsIdleTimeout drops an /events connection that stopped sending
+	// heartbeats.
+	eventsIdleTimeout = 60 * time.Second
+	// eventsMinRetry and eventsMaxRetry bound the reconnect backoff.
+	eventsMinRetry = 5 * time.Second
+	eventsMaxRetry = 5 * time.Minute
+	// eventsCheckSpread spreads the checks triggered by a broadcast over a
+	// few seconds, so that a hotfix doesn't hit the server with every
+	// launcher at once.
+	eventsCheckSpread = 5 * time.Second
+)
+
+// Maintenance is a maintenance notice pushed by the server.
+type Maintenance struct {
+	Active  bool       `json:"active"`
+	Message string     `json:"message"`
+	Until   *time.Time `json:"until"`
+}
+
+// serverSentEvent is one event read from an event stream.
+type serverSentEvent struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// subscribeEvents follows the backend's /events stream until ctx is done,
+// reconnecting with backoff and resuming from the last event ID. Backends
+// without /events are left to the polling in watchUpdates.
+func (p *product) subscribeEvents(ctx context.Context) {
+	if BuildConfig.UpdateCheck.DisableEvents {
+		return
+	}
+	_, _, policy := updateCheckSettings()
+
+	var lastID string
+	retry := eventsMinRetry
+	failures := 0
+	for {
+		connected := time.Now()
+		err := p.readEvents(ctx, &lastID, &retry, policy)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errEventsUnsupported) {
+			logger.Info("backend has no event stream, relying on polling", "product", p.config.ID)
+			return
+		}
+
+		// A connection that stayed up for a while resets the backoff.
+		if time.Since(connected) > eventsMaxRetry {
+			failures = 0
+		}
+		delay := retry
+		for i := 0; i < failures && delay < eventsMaxRetry; i++ {
+			delay *= 2
+		}
+		if delay > eventsMaxRetry {
+			delay = eventsMaxRetry
+		}
+		delay += time.Duration(rand.Int64N(int64(delay)/4 + 1))
+		failures++
+		logger.Debug("event stream closed", "product", p.config.ID, "err", err, "reconnectIn", delay.String())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// errEventsUnsupported is returned for backends that don't serve /events.
+var errEventsUnsupported = errors.New("event stream not supported")
+
+// readEvents reads one connection to /events and dispatches its events.
+func (p *product) readEvents(ctx context.Context, lastID *string, retry *time.Duration, policy string) error {
+	path := "/events"
+	if *lastID != "" {
+		path += "?lastEventId=" + *lastID
+	}
+	resp, err := p.request(http.MethodGet, path, nil, eventsIdleTimeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+	defer stop()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errEventsUnsupported
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return errors.New("unexpected response from /events: " + resp.Status)
+	}
+	logger.Debug("event stream connected", "product", p.config.ID)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var event serverSentEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 || event.Type != "" {
+				event.Data = strings.Join(data, "\n")
+				if event.ID != "" {
+					*lastID = event.ID
+				}
+				p.handleServerEvent(event, policy)
+			}
+			event, data = serverSentEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				*retry = max(time.Duration(ms)*time.Millisecond, eventsMinRetry)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// handleServerEvent reacts to an event from the backend. Manifest and
+// version changes run a background check shortly after; maintenance notices
+// are forwarded to the frontend.
+func (p *product) handleServerEvent(event serverSentEvent, policy string) {
+	logger.Debug("server event", "product", p.config.ID, "type", event.Type, "id", event.ID)
+	switch event.Type {
+	case "manifest", "version", "reset":
+		delay := time.Duration(rand.Int64N(int64(eventsCheckSpread)))
+		time.AfterFunc(delay, func() {
+			if err := p.backgroundCheck(policy); err != nil {
+				logger.Warn("update check after server event failed", "product", p.config.ID, "err", err)
+			}
+		})
+	case "maintenance":
+		var notice Maintenance
+		if err := json.Unmarshal([]byte(event.Data), &notice); err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.maintenance = notice
+		p.mu.Unlock()
+		p.emit("maintenance", notice)
+	}
+}
+
+// Maintenance returns the last maintenance notice pushed by the backend of
+// the selected product.
+func (a *App) Maintenance() Maintenance {
+	p := a.current()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.maintenance
+}
@@ -1,7 +1,46 @@
 import { useEffect, useState } from "react";
 import logo from "./assets/images/logo.jpeg";
-import { EventsOn, EventsOff, EventsEmit } from "../wailsjs/runtime/runtime";
-import { ManualUpdate, Config, StartExecutable } from "../wailsjs/go/main/App";
+import {
+  EventsOn,
+  EventsOff,
+  EventsEmit,
+  BrowserOpenURL,
+} from "../wailsjs/runtime/runtime";
+import {
+  ManualUpdate,
+  Config,
+  StartExecutable,
+  Groups,
+  SetGroups,
+  InstallDir,
+  DefaultInstallDir,
+  ChooseInstallDir,
+  SetInstallDir,
+  MoveInstall,
+  Products,
+  SelectProduct,
+  LaunchProduct,
+  News,
+  ExportDiagnostics,
+  Activate,
+  Activation,
+  Deactivate,
+  ImportBundle,
+  Maintenance,
+  PreviousVersion,
+  RollbackToPrevious,
+  ConfigReport,
+  Language,
+  Languages,
+  SetLanguage,
+  Translations,
+  VersionStatus,
+  AvailableVersions,
+  InstallVersion,
+  Telemetry,
+  SetTelemetry,
+} from "../wailsjs/go/main/App";
+import { main } from "../wailsjs/go/models";
 
 type DownloadStatus =
   | "idle"
@@ -9,101 +48,75 @@ type DownloadStatus =
   | "downloading"
   | "ready"
   | "error"
-  | "alreadyReady";
-
-const DownloadStatusMapping: { [key in DownloadStatus]: string } = {
-  idle: "",
-  checking: "Checking for updates...",
-  downloading: "Downloading files...",
-  ready: "Ready",
-  error: "Error occurred during update",
-  alreadyReady: "Your files are up to date",
-};
+  | "alreadyReady"
+  | "activationRequired"
+  | "importing"
+  | "updateAvailable"
+  | "rollingBack";
+
+type Messages = { [key: string]: string };
+
+// translate returns the message for key with {name} placeholders filled in
+// from vars. The catalogues come from the Go side through Translations(),
+// which always includes the English messages, so a missing key only happens
+// before they have loaded.
+const translate = (
+  messages: Messages,
+  key: string,
+  vars: { [name: string]: string | number } = {}
+) =>
+  Object.entries(vars).reduce(
+    (message, [name, value]) => message.split(`{${name}}`).join(String(value)),
+    messages[key] ?? ""
+  );
+
+// The neutral palette, shown until the configured theme has loaded. The
+// patcher serves the complete theme through Config().
+const DEFAULT_THEME = main.Theme.createFrom({
+  primary: "#6b7280",
+  primaryHover: "#4b5563",
+  secondary: "#9ca3af",
+  secondaryHover: "#6b7280",
+  backgroundGradient: ["#f9fafb", "#f3f4f6"],
+  textPrimary: "#374151",
+  textSecondary: "#6b7280",
+  success: "#059669",
+  error: "#dc2626",
+  info: "#2563eb",
+  cardBg: "#ffffff",
+  progressBg: "#e5e7eb",
+  disabled: "#d1d5db",
+  disabledText: "#9ca3af",
+  backgroundImage: "",
+  font: "",
+  fontUrl: "",
+});
 
-type ColorPaletteKey = "neutral" | "blue" | "green" | "purple";
-type ColorPalette = {
-  primary: string;
-  primaryHover: string;
-  secondary: string;
-  secondaryHover: string;
-  background: string;
-  textPrimary: string;
-  textSecondary: string;
-  success: string;
-  error: string;
-  info: string;
-  cardBg: string;
-  progressBg: string;
-  disabled: string;
-  disabledText: string;
+const DEFAULT_FONT =
+  "'Inter', -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif";
+
+// themeBackground returns the CSS background of the window: the gradient,
+// with the background image on top.
+const themeBackground = (theme: main.Theme) => {
+  const stops = theme.backgroundGradient || [];
+  const gradient =
+    stops.length > 1
+      ? `linear-gradient(135deg, ${stops.join(", ")})`
+      : stops[0] || DEFAULT_THEME.cardBg;
+  return theme.backgroundImage
+    ? `url("${theme.backgroundImage}") center / cover no-repeat, ${gradient}`
+    : gradient;
 };
 
-// Color palettes
-const COLOR_PALETTES: Record<ColorPaletteKey, ColorPalette> = {
-  neutral: {
-    primary: "#6b7280",
-    primaryHover: "#4b5563",
-    secondary: "#9ca3af",
-    secondaryHover: "#6b7280",
-    background: "linear-gradient(135deg, #f9fafb 0%, #f3f4f6 100%)",
-    textPrimary: "#374151",
-    textSecondary: "#6b7280",
-    success: "#059669",
-    error: "#dc2626",
-    info: "#2563eb",
-    cardBg: "#ffffff",
-    progressBg: "#e5e7eb",
-    disabled: "#d1d5db",
-    disabledText: "#9ca3af",
-  },
-  blue: {
-    primary: "#3182ce",
-    primaryHover: "#2b6cb0",
-    secondary: "#63b3ed",
-    secondaryHover: "#4299e1",
-    background: "linear-gradient(135deg, #ebf8ff 0%, #bee3f8 100%)",
-    textPrimary: "#2a4365",
-    textSecondary: "#4c51bf",
-    success: "#38a169",
-    error: "#e53e3e",
-    info: "#3182ce",
-    cardBg: "#ffffff",
-    progressBg: "#e6fffa",
-    disabled: "#93c5fd",
-    disabledText: "#93c5fd",
-  },
-  green: {
-    primary: "#38a169",
-    primaryHover: "#2f855a",
-    secondary: "#68d391",
-    secondaryHover: "#48bb78",
-    background: "linear-gradient(135deg, #f0fff4 0%, #c6f6d5 100%)",
-    textPrimary: "#22543d",
-    textSecondary: "#38a169",
-    success: "#38a169",
-    error: "#e53e3e",
-    info: "#3182ce",
-    cardBg: "#ffffff",
-    progressBg: "#e6fffa",
-    disabled: "#9ae6b4",
-    disabledText: "#9ae6b4",
-  },
-  purple: {
-    primary: "#805ad5",
-    primaryHover: "#6b46c1",
-    secondary: "#9f7aea",
-    secondaryHover: "#805ad5",
-    background: "linear-gradient(135deg, #faf5ff 0%, #e9d8fd 100%)",
-    textPrimary: "#322659",
-    textSecondary: "#553c9a",
-    success: "#38a169",
-    error: "#e53e3e",
-    info: "#3182ce",
-    cardBg: "#ffffff",
-    progressBg: "#e6fffa",
-    disabled: "#d6bcfa",
-    disabledText: "#d6bcfa",
-  },
+const formatSize = (bytes: number) => {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let size = bytes;
+  let unit = 0;
+  while (size >= 1024 && unit < units.length - 1) {
+    size /= 1024;
+    unit++;
+  }
+  return `${size.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
 };
 
 // Keyframes for animations
@@ -140,7 +153,7 @@ document.head.appendChild(styleSheet);
 function App() {
   const [config, setConfig] = useState({
     displayName: "PPatcher",
-    colorPalette: "neutral",
+    theme: DEFAULT_THEME,
     showStartButton: false,
     version: "",
     description: "",
@@ -154,9 +167,60 @@ function App() {
   const [statusKey, setStatusKey] = useState(0);
   const [isCheckButtonDisabled, setIsCheckButtonDisabled] = useState(false);
   const [isStartButtonDisabled, setIsStartButtonDisabled] = useState(false);
+  const [groups, setGroups] = useState<main.GroupInfo[]>([]);
+  const [installDir, setInstallDir] = useState("");
+  const [pendingInstallDir, setPendingInstallDir] = useState("");
+  const [products, setProducts] = useState<main.ProductInfo[]>([]);
+  const [launchProfile, setLaunchProfile] = useState("");
+  const [news, setNews] = useState<main.NewsFeed | null>(null);
+  const [showChangelog, setShowChangelog] = useState(false);
+  const [activation, setActivation] = useState<main.ActivationInfo | null>(
+    null
+  );
+  const [licenseKey, setLicenseKey] = useState("");
+  const [activationError, setActivationError] = useState("");
+  const [availableUpdate, setAvailableUpdate] = useState<{
+    version: string;
+    totalSize: number;
+    download: boolean;
+  } | null>(null);
+  const [maintenance, setMaintenance] = useState<main.Maintenance | null>(
+    null
+  );
+  const [configErrors, setConfigErrors] = useState<string[]>([]);
+  const [previousVersion, setPreviousVersion] =
+    useState<main.PreviousVersion | null>(null);
+  const [messages, setMessages] = useState<Messages>({});
+  const [language, setLanguage] = useState("");
+  const [languages, setLanguages] = useState<main.LanguageOption[]>([]);
+  const [downloadError, setDownloadError] = useState("");
+  const [versionStatus, setVersionStatus] =
+    useState<main.VersionStatus | null>(null);
+  const [showVersions, setShowVersions] = useState(false);
+  const [versions, setVersions] = useState<main.AvailableVersion[] | null>(
+    null
+  );
+  const [versionsError, setVersionsError] = useState("");
+  const [telemetry, setTelemetry] = useState<main.TelemetryInfo | null>(null);
+
+  const t = (key: string, vars?: { [name: string]: string | number }) =>
+    translate(messages, key, vars);
 
-  // Get the current color palette
-  const colors = COLOR_PALETTES[config.colorPalette as ColorPaletteKey];
+  // Get the current theme
+  const colors = config.theme;
+
+  // With several products the header, logo and Start button follow the
+  // selected one.
+  const currentProduct =
+    products.length > 1 ? products.find((product) => product.selected) : undefined;
+  const launchTarget = currentProduct || products[0];
+  // A required update blocks the Start button until it is installed.
+  const updateRequired = versionStatus?.state === "required";
+  const startDisabled = isStartButtonDisabled || updateRequired;
+  const productLogo =
+    currentProduct && /^(https?:|data:)/.test(currentProduct.logo)
+      ? currentProduct.logo
+      : logo;
 
   // Calculate responsive dimensions
   const windowHeight = window.innerHeight;
@@ -164,11 +228,46 @@ function App() {
   const imageHeight = Math.min(280, windowHeight * 0.3);
 
   useEffect(() => {
+    const loadLanguages = () => {
+      Language()
+        .then((code) => setLanguage(code))
+        .catch(() => {});
+      Languages()
+        .then((options) => setLanguages(options || []))
+        .catch(() => {});
+    };
+
+    Translations()
+      .then((messages) => setMessages(messages || {}))
+      .catch(() => {});
+    loadLanguages();
+
+    EventsOn("languageChanged", (messages: Messages) => {
+      setMessages(messages || {});
+      loadLanguages();
+    });
+
+    EventsOn("downloadError", (message: string) => {
+      setDownloadError(message);
+    });
+
+    VersionStatus()
+      .then((status) => setVersionStatus(status))
+      .catch(() => {});
+
+    Telemetry()
+      .then((info) => setTelemetry(info))
+      .catch(() => {});
+
+    EventsOn("versionStatus", (status: main.VersionStatus) => {
+      setVersionStatus(status);
+    });
+
     Config()
       .then((config) => {
         setConfig({
           displayName: config.displayName || "PPatcher",
-          colorPalette: config.colorPalette,
+          theme: config.theme || DEFAULT_THEME,
           showStartButton: !!config.executable,
           version: config.version || "",
           description: config.description || "",
@@ -176,14 +275,77 @@ function App() {
       })
       .catch(() => {});
 
+    Products()
+      .then((products) => setProducts(products || []))
+      .catch(() => {});
+
+    ConfigReport()
+      .then((report) => setConfigErrors(report.errors || []))
+      .catch(() => {});
+
+    InstallDir()
+      .then((dir) => {
+        setInstallDir(dir);
+        if (dir) {
+          return Groups().then((groups) => setGroups(groups || []));
+        }
+      })
+      .catch(() => {});
+
+    EventsOn("news", (feed: main.NewsFeed) => {
+      setNews(feed);
+    });
+
+    Activation()
+      .then((info) => setActivation(info))
+      .catch(() => {});
+
+    EventsOn("activationRequired", (info: main.ActivationInfo) => {
+      setActivation(info);
+      setActivationError(info.activated ? info.error : "");
+    });
+
+    Maintenance()
+      .then((notice) => setMaintenance(notice))
+      .catch(() => {});
+
+    PreviousVersion()
+      .then((previous) => setPreviousVersion(previous))
+      .catch(() => {});
+
+    EventsOn("maintenance", (notice: main.Maintenance) => {
+      setMaintenance(notice);
+    });
+
+    EventsOn(
+      "updateAvailable",
+      (update: { version: string; totalSize: number; download: boolean }) => {
+        if (update.download) {
+          setAvailableUpdate(update);
+        }
+      }
+    );
+
+    EventsOn("installDirRequired", (defaultDir: string) => {
+      setPendingInstallDir(defaultDir);
+    });
+
     EventsOn("downloadStatus", (newStatus: DownloadStatus) => {
       // Trigger status animation by updating the key
       setStatusKey((prevKey) => prevKey + 1);
+      if (newStatus !== "error") {
+        setDownloadError("");
+      }
 
       setDownloadState((oldStatus) => {
         if (newStatus === "ready" || newStatus === "alreadyReady") {
           setProgress(() => 1);
         }
+        if (newStatus === "ready") {
+          PreviousVersion()
+            .then((previous) => setPreviousVersion(previous))
+            .catch(() => {});
+        }
         if (
           (oldStatus === "ready" || oldStatus === "alreadyReady") &&
           newStatus === "ready"
@@ -210,9 +372,31 @@ function App() {
       EventsOff("downloadStatus");
       EventsOff("downloadProgress");
       EventsOff("versionUpdate");
+      EventsOff("installDirRequired");
+      EventsOff("activationRequired");
+      EventsOff("updateAvailable");
+      EventsOff("maintenance");
+      EventsOff("news");
+      EventsOff("languageChanged");
+      EventsOff("downloadError");
+      EventsOff("versionStatus");
     };
   }, []);
 
+  // Load the theme's font file, if it has one.
+  useEffect(() => {
+    const { font, fontUrl } = config.theme;
+    if (!font || !fontUrl) {
+      return;
+    }
+    const fontFace = document.createElement("style");
+    fontFace.innerText = `@font-face { font-family: '${font}'; src: url("${fontUrl}"); font-display: swap; }`;
+    document.head.appendChild(fontFace);
+    return () => {
+      document.head.removeChild(fontFace);
+    };
+  }, [config.theme.font, config.theme.fontUrl]);
+
   const onUpdateClick = (
     e: React.MouseEvent<HTMLButtonElement, MouseEvent>
   ) => {
@@ -229,6 +413,223 @@ function App() {
     });
   };
 
+  const onGroupToggle = (id: string) => {
+    const selected = groups
+      .filter((group) => (group.id === id ? !group.selected : group.selected))
+      .map((group) => group.id);
+
+    setIsStartButtonDisabled(true);
+    setIsCheckButtonDisabled(true);
+
+    SetGroups(selected)
+      .then(() => Groups())
+      .then((groups) => setGroups(groups || []))
+      .then(() => ManualUpdate())
+      .catch(() => {})
+      .finally(() => {
+        setIsCheckButtonDisabled(false);
+        setIsStartButtonDisabled(false);
+      });
+  };
+
+  const onSelectProduct = (id: string) => {
+    SelectProduct(id)
+      .then(() => Products())
+      .then((products) => {
+        setProducts(products || []);
+        const selected = (products || []).find((product) => product.selected);
+        if (selected) {
+          setDownloadState((selected.status || "idle") as DownloadStatus);
+          setDownloadError("");
+          setProgress(() =>
+            selected.status === "ready" || selected.status === "alreadyReady"
+              ? 1
+              : 0
+          );
+          setConfig((prev) => ({ ...prev, version: selected.version }));
+        }
+        setLaunchProfile("");
+        setGroups([]);
+        News()
+          .then((feed) => setNews(feed))
+          .catch(() => {});
+        Maintenance()
+          .then((notice) => setMaintenance(notice))
+          .catch(() => {});
+        PreviousVersion()
+          .then((previous) => setPreviousVersion(previous))
+          .catch(() => {});
+        VersionStatus()
+          .then((status) => setVersionStatus(status))
+          .catch(() => {});
+        Activation()
+          .then((info) => setActivation(info))
+          .catch(() => {});
+        setActivationError("");
+        return InstallDir();
+      })
+      .then((dir) => {
+        setInstallDir(dir);
+        setPendingInstallDir("");
+        if (dir) {
+          return Groups().then((groups) => setGroups(groups || []));
+        }
+        return DefaultInstallDir().then((dir) => setPendingInstallDir(dir));
+      })
+      .catch(() => {});
+  };
+
+  // News HTML is rendered and sanitised by the Go side; links open in the
+  // system browser instead of the launcher window.
+  const onNewsClick = (e: React.MouseEvent<HTMLDivElement, MouseEvent>) => {
+    const link = (e.target as HTMLElement).closest("a");
+    if (link && link.href) {
+      e.preventDefault();
+      BrowserOpenURL(link.href);
+    }
+  };
+
+  const severityColor = (severity: string) => {
+    switch (severity) {
+      case "critical":
+        return colors.error;
+      case "warning":
+        return "#d97706";
+      default:
+        return colors.info;
+    }
+  };
+
+  const onBrowseInstallDir = () => {
+    ChooseInstallDir()
+      .then((dir) => dir && setPendingInstallDir(dir))
+      .catch(() => {});
+  };
+
+  const onConfirmInstallDir = () => {
+    SetInstallDir(pendingInstallDir)
+      .then(() => {
+        setInstallDir(pendingInstallDir);
+        setPendingInstallDir("");
+        return Groups().then((groups) => setGroups(groups || []));
+      })
+      .then(() => ManualUpdate())
+      .catch(() => {});
+  };
+
+  const onMoveInstall = () => {
+    ChooseInstallDir()
+      .then((dir) => {
+        if (!dir) {
+          return;
+        }
+        setIsStartButtonDisabled(true);
+        setIsCheckButtonDisabled(true);
+        return MoveInstall(dir).then(() => setInstallDir(dir));
+      })
+      .catch(() => {})
+      .finally(() => {
+        setIsCheckButtonDisabled(false);
+        setIsStartButtonDisabled(false);
+      });
+  };
+
+  const onActivate = () => {
+    setActivationError("");
+    Activate(licenseKey)
+      .then((info) => {
+        setActivation(info);
+        setLicenseKey("");
+        return ManualUpdate();
+      })
+      .catch((err) => setActivationError(String(err)));
+  };
+
+  const onDeactivate = () => {
+    Deactivate()
+      .then(() => Activation())
+      .then((info) => setActivation(info))
+      .catch(() => {});
+  };
+
+  const onImportBundle = () => {
+    setIsStartButtonDisabled(true);
+    setIsCheckButtonDisabled(true);
+    ImportBundle()
+      .catch(() => {})
+      .finally(() => {
+        setIsCheckButtonDisabled(false);
+        setIsStartButtonDisabled(false);
+      });
+  };
+
+  const onLanguageChange = (code: string) => {
+    SetLanguage(code).catch(() => {});
+  };
+
+  const onRollback = () => {
+    const question = previousVersion?.version
+      ? t("rollback.confirm", { version: previousVersion.version })
+      : t("rollback.confirmPrevious");
+    if (!window.confirm(question)) {
+      return;
+    }
+    setIsStartButtonDisabled(true);
+    setIsCheckButtonDisabled(true);
+    RollbackToPrevious()
+      .catch(() => {})
+      .finally(() => {
+        PreviousVersion()
+          .then((previous) => setPreviousVersion(previous))
+          .catch(() => {});
+        setIsCheckButtonDisabled(false);
+        setIsStartButtonDisabled(false);
+      });
+  };
+
+  const onToggleVersions = () => {
+    if (showVersions) {
+      setShowVersions(false);
+      return;
+    }
+    setShowVersions(true);
+    setVersions(null);
+    setVersionsError("");
+    AvailableVersions()
+      .then((list) => setVersions(list || []))
+      .catch((err) => setVersionsError(String(err)));
+  };
+
+  // An empty version returns to the latest release.
+  const onInstallVersion = (version: string) => {
+    if (version && !window.confirm(t("versions.confirm", { version }))) {
+      return;
+    }
+    setShowVersions(false);
+    setIsStartButtonDisabled(true);
+    setIsCheckButtonDisabled(true);
+    InstallVersion(version)
+      .catch(() => {})
+      .finally(() => {
+        PreviousVersion()
+          .then((previous) => setPreviousVersion(previous))
+          .catch(() => {});
+        setIsCheckButtonDisabled(false);
+        setIsStartButtonDisabled(false);
+      });
+  };
+
+  const onTelemetryChange = (enabled: boolean) => {
+    SetTelemetry(enabled)
+      .then(() => Telemetry())
+      .then((info) => setTelemetry(info))
+      .catch(() => {});
+  };
+
+  const onExportDiagnostics = () => {
+    ExportDiagnostics().catch(() => {});
+  };
+
   const onStartClick = (e: React.MouseEvent<HTMLButtonElement, MouseEvent>) => {
     setIsStartButtonDisabled(true);
     setIsCheckButtonDisabled(true);
@@ -236,7 +637,11 @@ function App() {
     setIsStartButtonClicked(true);
     setTimeout(() => setIsStartButtonClicked(false), 200);
 
-    StartExecutable().finally(() => {
+    const start = launchTarget
+      ? LaunchProduct(launchTarget.id, launchProfile)
+      : StartExecutable();
+
+    start.catch(() => {}).finally(() => {
       setTimeout(() => {
         setIsStartButtonDisabled(false);
         setIsCheckButtonDisabled(false);
@@ -263,17 +668,41 @@ function App() {
       id="App"
       style={{
         ...styles.app,
-        background: colors.background,
+        background: themeBackground(colors),
+        fontFamily: colors.font
+          ? `'${colors.font}', ${DEFAULT_FONT}`
+          : DEFAULT_FONT,
         height: "100vh",
       }}
     >
+      {products.length > 1 && (
+        <div style={styles.productTabs}>
+          {products.map((product) => (
+            <button
+              key={product.id}
+              onClick={() => onSelectProduct(product.id)}
+              disabled={isCheckButtonDisabled}
+              style={{
+                ...styles.productTab,
+                color: product.selected ? "white" : colors.textPrimary,
+                backgroundColor: product.selected
+                  ? colors.primary
+                  : colors.cardBg,
+              }}
+            >
+              {product.displayName}
+            </button>
+          ))}
+        </div>
+      )}
+
       <div style={styles.content}>
         <div style={styles.header}>
           <h1 style={{ ...styles.title, color: colors.textPrimary }}>
-            {config.displayName || "PPatcher"}
+            {currentProduct?.displayName || config.displayName || "PPatcher"}
           </h1>
           <p style={{ ...styles.subtitle, color: colors.textSecondary }}>
-            {config.description}
+            {currentProduct ? currentProduct.description : config.description}
           </p>
         </div>
 
@@ -281,7 +710,7 @@ function App() {
           style={{ ...styles.logoContainer, backgroundColor: colors.cardBg }}
         >
           <img
-            src={logo}
+            src={productLogo}
             alt="PPatcher Logo"
             style={{ ...styles.logo, height: imageHeight }}
           />
@@ -298,10 +727,46 @@ function App() {
               minHeight: "24px",
             }}
           >
-            {DownloadStatusMapping[downloadState]}
+            {t(`status.${downloadState}`)}
           </div>
 
-          {downloadState === "downloading" && (
+          {downloadState === "error" && downloadError && (
+            <div style={{ ...styles.installPath, color: colors.error }}>
+              {downloadError}
+            </div>
+          )}
+
+          {versionStatus?.state === "optional" &&
+            downloadState !== "updateAvailable" &&
+            downloadState !== "downloading" && (
+              <div style={{ ...styles.installPath, color: colors.info }}>
+                {t("version.optional", { version: versionStatus.latest })}
+              </div>
+            )}
+
+          {versionStatus?.state === "pinned" && (
+            <div style={{ ...styles.installPath, color: colors.info }}>
+              {t("version.pinned", { version: versionStatus.pinned })}{" "}
+              <span
+                onClick={() => onInstallVersion("")}
+                style={{ cursor: "pointer", textDecoration: "underline" }}
+              >
+                {t("versions.unpin")}
+              </span>
+            </div>
+          )}
+
+          {versionStatus?.state === "newer" && (
+            <div style={{ ...styles.installPath, color: colors.textSecondary }}>
+              {t("version.newer", {
+                installed: versionStatus.installed,
+                latest: versionStatus.latest,
+              })}
+            </div>
+          )}
+
+          {(downloadState === "downloading" ||
+            downloadState === "importing") && (
             <div style={{ ...styles.progressText, color: colors.primary }}>
               {Math.round(progress * 100)}%
             </div>
@@ -336,31 +801,337 @@ function App() {
           </div>
         </div>
 
+        {configErrors.length > 0 && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.error }}>{t("config.problems")}</div>
+            {configErrors.map((problem) => (
+              <div
+                key={problem}
+                style={{ ...styles.installPath, color: colors.textSecondary }}
+              >
+                {problem}
+              </div>
+            ))}
+          </div>
+        )}
+
+        {maintenance?.active && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.error }}>
+              {maintenance.message || t("maintenance.default")}
+            </div>
+            {maintenance.until && (
+              <div style={{ ...styles.installPath, color: colors.textSecondary }}>
+                {t("maintenance.until", {
+                  time: new Date(maintenance.until).toLocaleString(language || undefined),
+                })}
+              </div>
+            )}
+          </div>
+        )}
+
+        {pendingInstallDir && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.textPrimary }}>
+              {t("installDir.choose")}
+            </div>
+            <div style={{ ...styles.installPath, color: colors.textSecondary }}>
+              {pendingInstallDir}
+            </div>
+            <div style={styles.groupItem}>
+              <button
+                onClick={onBrowseInstallDir}
+                style={{
+                  ...styles.button,
+                  backgroundColor: colors.secondary,
+                  color: "white",
+                }}
+              >
+                {t("installDir.browse")}
+              </button>
+              <button
+                onClick={onConfirmInstallDir}
+                style={{
+                  ...styles.button,
+                  backgroundColor: colors.primary,
+                  color: "white",
+                }}
+              >
+                {t("installDir.confirm")}
+              </button>
+            </div>
+          </div>
+        )}
+
+        {updateRequired && downloadState !== "downloading" && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.error }}>
+              {t("version.required", { version: versionStatus?.latest ?? "" })}
+            </div>
+            <button
+              onClick={
+                versionStatus?.pinned
+                  ? () => onInstallVersion("")
+                  : onUpdateClick
+              }
+              disabled={isCheckButtonDisabled}
+              style={{
+                ...styles.button,
+                backgroundColor: colors.primary,
+                color: "white",
+              }}
+            >
+              {t("update.download")}
+            </button>
+          </div>
+        )}
+
+        {showVersions && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ ...styles.groupItem, color: colors.textPrimary }}>
+              <span style={{ flex: 1, fontWeight: 600 }}>
+                {t("versions.title")}
+              </span>
+              <span
+                onClick={() => setShowVersions(false)}
+                style={{ color: colors.textSecondary, cursor: "pointer" }}
+              >
+                {t("versions.close")}
+              </span>
+            </div>
+            {versionsError && (
+              <div style={{ color: colors.error }}>{versionsError}</div>
+            )}
+            {!versionsError && versions === null && (
+              <div style={{ color: colors.textSecondary }}>
+                {t("versions.loading")}
+              </div>
+            )}
+            {versions?.length === 0 && (
+              <div style={{ color: colors.textSecondary }}>
+                {t("versions.none")}
+              </div>
+            )}
+            <div style={styles.versionList}>
+              {(versions || []).map((version) => (
+                <div
+                  key={version.version}
+                  style={{ ...styles.groupItem, color: colors.textPrimary }}
+                >
+                  <span style={{ flex: 1 }}>
+                    {version.version}
+                    {version.latest && (
+                      <span style={{ color: colors.info }}>
+                        {" "}
+                        · {t("versions.latest")}
+                      </span>
+                    )}
+                    {version.installed && (
+                      <span style={{ color: colors.success }}>
+                        {" "}
+                        · {t("versions.installed")}
+                      </span>
+                    )}
+                  </span>
+                  <span style={{ color: colors.textSecondary }}>
+                    {formatSize(version.totalSize)}
+                  </span>
+                  <button
+                    onClick={() =>
+                      onInstallVersion(version.latest ? "" : version.version)
+                    }
+                    disabled={
+                      isCheckButtonDisabled ||
+                      (version.installed &&
+                        (version.pinned ||
+                          (version.latest && !versionStatus?.pinned)))
+                    }
+                    style={{
+                      ...styles.smallButton,
+                      backgroundColor: colors.secondary,
+                      color: "white",
+                    }}
+                  >
+                    {t("versions.install")}
+                  </button>
+                </div>
+              ))}
+            </div>
+          </div>
+        )}
+
+        {downloadState === "updateAvailable" && availableUpdate && !updateRequired && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.textPrimary }}>
+              {t("update.available", {
+                version: availableUpdate.version,
+                size: formatSize(availableUpdate.totalSize),
+              })}
+            </div>
+            <button
+              onClick={onUpdateClick}
+              style={{
+                ...styles.button,
+                backgroundColor: colors.primary,
+                color: "white",
+              }}
+            >
+              {t("update.download")}
+            </button>
+          </div>
+        )}
+
+        {downloadState === "activationRequired" && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            <div style={{ color: colors.textPrimary }}>
+              {t("license.prompt")}
+            </div>
+            <input
+              value={licenseKey}
+              onChange={(e) => setLicenseKey(e.target.value)}
+              onKeyDown={(e) => e.key === "Enter" && onActivate()}
+              placeholder="XXXX-XXXX-XXXX-XXXX"
+              spellCheck={false}
+              style={{
+                ...styles.licenseInput,
+                color: colors.textPrimary,
+                borderColor: colors.textSecondary,
+              }}
+            />
+            {activationError && (
+              <div style={{ ...styles.installPath, color: colors.error }}>
+                {activationError}
+              </div>
+            )}
+            <button
+              onClick={onActivate}
+              disabled={!licenseKey.trim()}
+              style={{
+                ...styles.button,
+                backgroundColor: colors.primary,
+                color: "white",
+              }}
+            >
+              {t("license.activate")}
+            </button>
+          </div>
+        )}
+
+        {news && ((news.items || []).length > 0 || news.changelogHtml) && (
+          <div
+            onClick={onNewsClick}
+            style={{ ...styles.newsList, backgroundColor: colors.cardBg }}
+          >
+            {(news.items || []).map((item) => (
+              <div
+                key={item.id}
+                style={{
+                  ...styles.newsItem,
+                  borderLeftColor: severityColor(item.severity),
+                }}
+              >
+                <div style={{ ...styles.newsTitle, color: colors.textPrimary }}>
+                  {item.title}
+                  <span style={{ ...styles.newsDate, color: colors.textSecondary }}>
+                    {new Date(item.published).toLocaleDateString(language || undefined)}
+                  </span>
+                </div>
+                <div
+                  style={{ color: colors.textSecondary }}
+                  dangerouslySetInnerHTML={{ __html: item.html }}
+                />
+              </div>
+            ))}
+            {news.changelogHtml && (
+              <div style={styles.newsItem}>
+                <div
+                  onClick={() => setShowChangelog((show) => !show)}
+                  style={{
+                    ...styles.newsTitle,
+                    color: colors.textPrimary,
+                    cursor: "pointer",
+                  }}
+                >
+                  {t("news.whatsNew", { version: news.version })}
+                </div>
+                {showChangelog && (
+                  <div
+                    style={{ color: colors.textSecondary }}
+                    dangerouslySetInnerHTML={{ __html: news.changelogHtml }}
+                  />
+                )}
+              </div>
+            )}
+          </div>
+        )}
+
+        {groups.length > 1 && (
+          <div style={{ ...styles.groupList, backgroundColor: colors.cardBg }}>
+            {groups.map((group) => (
+              <label
+                key={group.id}
+                title={group.description}
+                style={{ ...styles.groupItem, color: colors.textPrimary }}
+              >
+                <input
+                  type="checkbox"
+                  checked={group.selected}
+                  disabled={group.required || isCheckButtonDisabled}
+                  onChange={() => onGroupToggle(group.id)}
+                />
+                <span style={{ flex: 1 }}>{group.name || group.id}</span>
+                <span style={{ color: colors.textSecondary }}>
+                  {formatSize(group.size)}
+                </span>
+              </label>
+            ))}
+          </div>
+        )}
+
         <div
           style={{
             ...styles.buttonContainer,
             justifyContent: config.showStartButton ? "center" : "center",
           }}
         >
-          {config.showStartButton && (
+          {(launchTarget?.launchProfiles || []).length > 0 && (
+            <select
+              value={launchProfile}
+              onChange={(e) => setLaunchProfile(e.target.value)}
+              style={{ ...styles.profileSelect, color: colors.textPrimary }}
+            >
+              <option value="">{t("launch.defaultProfile")}</option>
+              {launchTarget.launchProfiles.map((profile) => (
+                <option key={profile.id} value={profile.id}>
+                  {profile.name || profile.id}
+                </option>
+              ))}
+            </select>
+          )}
+          {(launchTarget ? launchTarget.launchable : config.showStartButton) && (
             <button
               onClick={onStartClick}
-              disabled={isStartButtonDisabled}
+              disabled={startDisabled}
+              title={
+                updateRequired
+                  ? t("version.required", { version: versionStatus?.latest ?? "" })
+                  : undefined
+              }
               onMouseEnter={() =>
-                !isStartButtonDisabled && setIsStartHovered(true)
+                !startDisabled && setIsStartHovered(true)
               }
               onMouseLeave={() => setIsStartHovered(false)}
               style={{
                 ...styles.button,
-                backgroundColor: isStartButtonDisabled
+                backgroundColor: startDisabled
                   ? colors.disabled
                   : colors.primary,
-                color: isStartButtonDisabled ? colors.disabledText : "white",
+                color: startDisabled ? colors.disabledText : "white",
                 animation: isStartButtonClicked
                   ? "buttonClick 0.2s ease"
                   : "none",
-                cursor: isStartButtonDisabled ? "not-allowed" : "pointer",
-                ...(!isStartButtonDisabled &&
+                cursor: startDisabled ? "not-allowed" : "pointer",
+                ...(!startDisabled &&
                   isStartHovered && {
                     backgroundColor: colors.primaryHover,
                     transform: "translateY(-2px)",
@@ -368,7 +1139,7 @@ function App() {
                   }),
               }}
             >
-              Start
+              {t("launch.start")}
             </button>
           )}
           <button
@@ -396,15 +1167,131 @@ function App() {
                 }),
             }}
           >
-            Check for Updates
+            {t("update.check")}
           </button>
         </div>
       </div>
 
       <div style={styles.footer}>
         <p style={{ ...styles.footerText, color: colors.textSecondary }}>
-          {`Version ${config.version || "1.0.0"}`}
+          {t("footer.version", { version: config.version || "1.0.0" })}
+        </p>
+        {installDir && (
+          <p
+            onClick={onMoveInstall}
+            title={t("installDir.move")}
+            style={{
+              ...styles.footerText,
+              ...styles.installPath,
+              color: colors.textSecondary,
+              cursor: "pointer",
+            }}
+          >
+            {installDir}
+          </p>
+        )}
+        {activation?.activated && (
+          <p
+            onClick={onDeactivate}
+            title={t("license.deactivateHint")}
+            style={{
+              ...styles.footerText,
+              color: colors.textSecondary,
+              cursor: "pointer",
+            }}
+          >
+            {activation.name
+              ? t("license.licensedTo", { name: activation.name })
+              : t("license.licensed")}
+          </p>
+        )}
+        {installDir && (
+          <p
+            onClick={onImportBundle}
+            title={t("bundle.importHint")}
+            style={{
+              ...styles.footerText,
+              color: colors.textSecondary,
+              cursor: "pointer",
+              textDecoration: "underline",
+            }}
+          >
+            {t("bundle.import")}
+          </p>
+        )}
+        {installDir && (
+          <p
+            onClick={onToggleVersions}
+            style={{
+              ...styles.footerText,
+              color: colors.textSecondary,
+              cursor: "pointer",
+              textDecoration: "underline",
+            }}
+          >
+            {t("versions.choose")}
+          </p>
+        )}
+        {previousVersion?.available && (
+          <p
+            onClick={onRollback}
+            title={t("rollback.hint")}
+            style={{
+              ...styles.footerText,
+              color: colors.textSecondary,
+              cursor: "pointer",
+              textDecoration: "underline",
+            }}
+          >
+            {previousVersion.version
+              ? t("rollback.to", { version: previousVersion.version })
+              : t("rollback.previous")}
+          </p>
+        )}
+        <p
+          onClick={onExportDiagnostics}
+          title={t("diagnostics.exportHint")}
+          style={{
+            ...styles.footerText,
+            color: colors.textSecondary,
+            cursor: "pointer",
+            textDecoration: "underline",
+          }}
+        >
+          {t("diagnostics.export")}
         </p>
+        {telemetry?.available && (
+          <label
+            title={t("telemetry.hint")}
+            style={{
+              ...styles.footerText,
+              ...styles.groupItem,
+              color: colors.textSecondary,
+              cursor: "pointer",
+            }}
+          >
+            <input
+              type="checkbox"
+              checked={telemetry.enabled}
+              onChange={(e) => onTelemetryChange(e.target.checked)}
+            />
+            {t("telemetry.optIn")}
+          </label>
+        )}
+        {languages.length > 1 && (
+          <select
+            value={language}
+            onChange={(e) => onLanguageChange(e.target.value)}
+            title={t("language.label")}
+            style={{ ...styles.languageSelect, color: colors.textSecondary }}
+          >
+            {languages.map((option) => (
+              <option key={option.code} value={option.code}>
+                {option.name}
+              </option>
+            ))}
+          </select>
+        )}
       </div>
     </div>
   );
@@ -416,8 +1303,7 @@ const styles = {
     flexDirection: "column" as "column",
     alignItems: "center",
     justifyContent: "space-between",
-    fontFamily:
-      "'Inter', -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif",
+    fontFamily: DEFAULT_FONT,
     padding: "20px",
     boxSizing: "border-box" as "border-box",
     overflow: "hidden",
@@ -486,6 +1372,84 @@ const styles = {
     borderRadius: "8px",
     transition: "width 0.3s ease",
   },
+  productTabs: {
+    display: "flex",
+    gap: "8px",
+    flexWrap: "wrap" as "wrap",
+    justifyContent: "center",
+  },
+  productTab: {
+    padding: "6px 14px",
+    borderRadius: "8px",
+    border: "none",
+    fontSize: "0.85rem",
+    cursor: "pointer",
+    boxShadow: "0 2px 6px rgba(0, 0, 0, 0.1)",
+  },
+  profileSelect: {
+    padding: "8px",
+    borderRadius: "8px",
+    border: "none",
+    fontSize: "0.85rem",
+  },
+  newsList: {
+    display: "flex",
+    flexDirection: "column" as "column",
+    gap: "10px",
+    padding: "10px 14px",
+    borderRadius: "8px",
+    width: "100%",
+    maxWidth: "520px",
+    maxHeight: "180px",
+    overflowY: "auto" as "auto",
+    boxSizing: "border-box" as "border-box",
+    fontSize: "0.85rem",
+    textAlign: "left" as "left",
+  },
+  newsItem: {
+    borderLeft: "3px solid transparent",
+    paddingLeft: "10px",
+  },
+  newsTitle: {
+    fontWeight: "600",
+    display: "flex",
+    justifyContent: "space-between",
+    gap: "8px",
+  },
+  newsDate: {
+    fontWeight: "400",
+    fontSize: "0.75rem",
+  },
+  groupList: {
+    display: "flex",
+    flexDirection: "column" as "column",
+    gap: "6px",
+    padding: "10px 14px",
+    borderRadius: "8px",
+    width: "100%",
+    maxWidth: "320px",
+    boxSizing: "border-box" as "border-box",
+    fontSize: "0.85rem",
+  },
+  groupItem: {
+    display: "flex",
+    alignItems: "center",
+    gap: "8px",
+  },
+  versionList: {
+    display: "flex",
+    flexDirection: "column" as "column",
+    gap: "6px",
+    maxHeight: "160px",
+    overflowY: "auto" as "auto",
+  },
+  smallButton: {
+    border: "none",
+    borderRadius: "6px",
+    padding: "4px 10px",
+    fontSize: "0.8rem",
+    cursor: "pointer",
+  },
   buttonContainer: {
     display: "flex",
     gap: "12px",
@@ -510,6 +1474,27 @@ const styles = {
     fontSize: "0.75rem",
     margin: 0,
   },
+  languageSelect: {
+    fontSize: "0.75rem",
+    border: "none",
+    background: "transparent",
+    cursor: "pointer",
+  },
+  licenseInput: {
+    padding: "8px 12px",
+    borderRadius: "8px",
+    border: "1px solid",
+    background: "transparent",
+    fontFamily: "monospace",
+    fontSize: "1rem",
+    textAlign: "center" as "center",
+    letterSpacing: "0.05em",
+  },
+  installPath: {
+    fontSize: "0.75rem",
+    wordBreak: "break-all" as "break-all",
+    textAlign: "center" as "center",
+  },
 };
 
 export default App;
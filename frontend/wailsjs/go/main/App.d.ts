@@ -2,16 +2,74 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function Activate(arg1:string):Promise<main.ActivationInfo>;
+
+export function Activation():Promise<main.ActivationInfo>;
+
+export function AvailableVersions():Promise<Array<main.AvailableVersion>>;
+
 export function BackendLog(arg1:string):Promise<void>;
 
+export function ChooseInstallDir():Promise<string>;
+
 export function Config():Promise<main.Config>;
 
+export function ConfigReport():Promise<main.ConfigReport>;
+
+export function Deactivate():Promise<void>;
+
+export function DefaultInstallDir():Promise<string>;
+
+export function ExportDiagnostics():Promise<string>;
+
+export function Groups():Promise<Array<main.GroupInfo>>;
+
+export function ImportBundle():Promise<string>;
+
+export function InstallDir():Promise<string>;
+
+export function InstallVersion(arg1:string):Promise<void>;
+
+export function Language():Promise<string>;
+
+export function Languages():Promise<Array<main.LanguageOption>>;
+
+export function LaunchProduct(arg1:string,arg2:string):Promise<void>;
+
+export function Maintenance():Promise<main.Maintenance>;
+
 export function ManualUpdate():Promise<void>;
 
+export function MoveInstall(arg1:string):Promise<void>;
+
+export function News():Promise<main.NewsFeed>;
+
+export function PreviousVersion():Promise<main.PreviousVersion>;
+
+export function ProductNews(arg1:string):Promise<main.NewsFeed>;
+
+export function Products():Promise<Array<main.ProductInfo>>;
+
+export function RollbackToPrevious():Promise<string>;
+
+export function SelectProduct(arg1:string):Promise<void>;
+
+export function SetGroups(arg1:Array<string>):Promise<void>;
+
+export function SetInstallDir(arg1:string):Promise<void>;
+
+export function SetLanguage(arg1:string):Promise<void>;
+
+export function SetTelemetry(arg1:boolean):Promise<void>;
+
 export function ShouldUpdate():Promise<boolean>;
 
 export function StartExecutable():Promise<void>;
 
+export function Telemetry():Promise<main.TelemetryInfo>;
+
+export function Translations():Promise<{[key: string]: string}>;
+
 export function Update():Promise<void>;
 
 export function UpdateCurrentFileData(arg1:string,arg2:number):Promise<void>;
@@ -19,3 +77,7 @@ export function UpdateCurrentFileData(arg1:string,arg2:number):Promise<void>;
 export function UpdateDownloadProgress(arg1:number):Promise<void>;
 
 export function UpdateDownloadStatus(arg1:string):Promise<void>;
+
+export function UpdateProduct(arg1:string):Promise<void>;
+
+export function VersionStatus():Promise<main.VersionStatus>;
@@ -2,18 +2,126 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function Activate(arg1) {
+  return window['go']['main']['App']['Activate'](arg1);
+}
+
+export function Activation() {
+  return window['go']['main']['App']['Activation']();
+}
+
+export function AvailableVersions() {
+  return window['go']['main']['App']['AvailableVersions']();
+}
+
 export function BackendLog(arg1) {
   return window['go']['main']['App']['BackendLog'](arg1);
 }
 
+export function ChooseInstallDir() {
+  return window['go']['main']['App']['ChooseInstallDir']();
+}
+
 export function Config() {
   return window['go']['main']['App']['Config']();
 }
 
+export function ConfigReport() {
+  return window['go']['main']['App']['ConfigReport']();
+}
+
+export function Deactivate() {
+  return window['go']['main']['App']['Deactivate']();
+}
+
+export function DefaultInstallDir() {
+  return window['go']['main']['App']['DefaultInstallDir']();
+}
+
+export function ExportDiagnostics() {
+  return window['go']['main']['App']['ExportDiagnostics']();
+}
+
+export function Groups() {
+  return window['go']['main']['App']['Groups']();
+}
+
+export function ImportBundle() {
+  return window['go']['main']['App']['ImportBundle']();
+}
+
+export function InstallDir() {
+  return window['go']['main']['App']['InstallDir']();
+}
+
+export function InstallVersion(arg1) {
+  return window['go']['main']['App']['InstallVersion'](arg1);
+}
+
+export function Language() {
+  return window['go']['main']['App']['Language']();
+}
+
+export function Languages() {
+  return window['go']['main']['App']['Languages']();
+}
+
+export function LaunchProduct(arg1, arg2) {
+  return window['go']['main']['App']['LaunchProduct'](arg1, arg2);
+}
+
+export function Maintenance() {
+  return window['go']['main']['App']['Maintenance']();
+}
+
 export function ManualUpdate() {
   return window['go']['main']['App']['ManualUpdate']();
 }
 
+export function MoveInstall(arg1) {
+  return window['go']['main']['App']['MoveInstall'](arg1);
+}
+
+export function News() {
+  return window['go']['main']['App']['News']();
+}
+
+export function PreviousVersion() {
+  return window['go']['main']['App']['PreviousVersion']();
+}
+
+export function ProductNews(arg1) {
+  return window['go']['main']['App']['ProductNews'](arg1);
+}
+
+export function Products() {
+  return window['go']['main']['App']['Products']();
+}
+
+export function RollbackToPrevious() {
+  return window['go']['main']['App']['RollbackToPrevious']();
+}
+
+export function SelectProduct(arg1) {
+  return window['go']['main']['App']['SelectProduct'](arg1);
+}
+
+export function SetGroups(arg1) {
+  return window['go']['main']['App']['SetGroups'](arg1);
+}
+
+export function SetInstallDir(arg1) {
+  return window['go']['main']['App']['SetInstallDir'](arg1);
+}
+
+export function SetLanguage(arg1) {
+  return window['go']['main']['App']['SetLanguage'](arg1);
+}
+
+export function SetTelemetry(arg1) {
+  return window['go']['main']['App']['SetTelemetry'](arg1);
+}
+
 export function ShouldUpdate() {
   return window['go']['main']['App']['ShouldUpdate']();
 }
@@ -22,6 +130,14 @@ export function StartExecutable() {
   return window['go']['main']['App']['StartExecutable']();
 }
 
+export function Telemetry() {
+  return window['go']['main']['App']['Telemetry']();
+}
+
+export function Translations() {
+  return window['go']['main']['App']['Translations']();
+}
+
 export function Update() {
   return window['go']['main']['App']['Update']();
 }
@@ -37,3 +153,11 @@ export function UpdateDownloadProgress(arg1) {
 export function UpdateDownloadStatus(arg1) {
   return window['go']['main']['App']['UpdateDownloadStatus'](arg1);
 }
+
+export function UpdateProduct(arg1) {
+  return window['go']['main']['App']['UpdateProduct'](arg1);
+}
+
+export function VersionStatus() {
+  return window['go']['main']['App']['VersionStatus']();
+}
@@ -1,5 +1,100 @@
 export namespace main {
 	
+	export class ActivationInfo {
+	    required: boolean;
+	    activated: boolean;
+	    name: string;
+	    // Go type: time
+	    expires: any;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ActivationInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.required = source["required"];
+	        this.activated = source["activated"];
+	        this.name = source["name"];
+	        this.expires = this.convertValues(source["expires"], null);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AvailableVersion {
+	    version: string;
+	    totalSize: number;
+	    created: any;
+	    latest: boolean;
+	    installed: boolean;
+	    pinned: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new AvailableVersion(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.totalSize = source["totalSize"];
+	        this.created = this.convertValues(source["created"], null);
+	        this.latest = source["latest"];
+	        this.installed = source["installed"];
+	        this.pinned = source["pinned"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BackupConfig {
+	    enabled: boolean;
+	    maxVersions: number;
+	    maxSizeMB: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackupConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.maxVersions = source["maxVersions"];
+	        this.maxSizeMB = source["maxSizeMB"];
+	    }
+	}
 	export class Config {
 	    backend: string;
 	    fallbackUrls: string[];
@@ -12,6 +107,17 @@ export namespace main {
 	    displayName: string;
 	    logo: string;
 	    icon: string;
+	    installDir: string;
+	    launchProfiles: LaunchProfile[];
+	    products: ProductConfig[];
+	    network: NetworkConfig;
+	    bundlePublicKey: string;
+	    updateCheck: UpdateCheckConfig;
+	    backup: BackupConfig;
+	    theme: Theme;
+	    language: string;
+	    translations: {[key: string]: {[key: string]: string}};
+	    telemetry: TelemetryConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -30,6 +136,490 @@ export namespace main {
 	        this.displayName = source["displayName"];
 	        this.logo = source["logo"];
 	        this.icon = source["icon"];
+	        this.installDir = source["installDir"];
+	        this.launchProfiles = this.convertValues(source["launchProfiles"], LaunchProfile);
+	        this.products = this.convertValues(source["products"], ProductConfig);
+	        this.network = this.convertValues(source["network"], NetworkConfig);
+	        this.bundlePublicKey = source["bundlePublicKey"];
+	        this.updateCheck = this.convertValues(source["updateCheck"], UpdateCheckConfig);
+	        this.backup = this.convertValues(source["backup"], BackupConfig);
+	        this.theme = this.convertValues(source["theme"], Theme);
+	        this.language = source["language"];
+	        this.translations = source["translations"];
+	        this.telemetry = this.convertValues(source["telemetry"], TelemetryConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ConfigReport {
+	    sources: {[key: string]: string};
+	    errors: string[];
+	    warnings: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ConfigReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sources = source["sources"];
+	        this.errors = source["errors"];
+	        this.warnings = source["warnings"];
+	    }
+	}
+	export class GroupInfo {
+	    id: string;
+	    name: string;
+	    description: string;
+	    required: boolean;
+	    default: boolean;
+	    size: number;
+	    files: number;
+	    selected: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new GroupInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.required = source["required"];
+	        this.default = source["default"];
+	        this.size = source["size"];
+	        this.files = source["files"];
+	        this.selected = source["selected"];
+	    }
+	}
+	export class LanguageOption {
+	    code: string;
+	    name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LanguageOption(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.code = source["code"];
+	        this.name = source["name"];
+	    }
+	}
+	export class LaunchProfile {
+	    id: string;
+	    name: string;
+	    executable: string;
+	    args: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new LaunchProfile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.executable = source["executable"];
+	        this.args = source["args"];
+	    }
+	}
+	export class Maintenance {
+	    active: boolean;
+	    message: string;
+	    until: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Maintenance(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.active = source["active"];
+	        this.message = source["message"];
+	        this.until = this.convertValues(source["until"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NetworkConfig {
+	    connectTimeout: string;
+	    idleTimeout: string;
+	    requestTimeout: string;
+	    proxy: string;
+	    caBundle: string;
+	    pins: string[];
+	    minDownloads: number;
+	    maxDownloads: number;
+	    batchThreshold: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.connectTimeout = source["connectTimeout"];
+	        this.idleTimeout = source["idleTimeout"];
+	        this.requestTimeout = source["requestTimeout"];
+	        this.proxy = source["proxy"];
+	        this.caBundle = source["caBundle"];
+	        this.pins = source["pins"];
+	        this.minDownloads = source["minDownloads"];
+	        this.maxDownloads = source["maxDownloads"];
+	        this.batchThreshold = source["batchThreshold"];
+	    }
+	}
+	export class NewsFeed {
+	    items: NewsItem[];
+	    version: string;
+	    changelog: string;
+	    changelogHtml: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewsFeed(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], NewsItem);
+	        this.version = source["version"];
+	        this.changelog = source["changelog"];
+	        this.changelogHtml = source["changelogHtml"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NewsItem {
+	    id: string;
+	    title: string;
+	    body: string;
+	    html: string;
+	    severity: string;
+	    // Go type: time
+	    published: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewsItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.body = source["body"];
+	        this.html = source["html"];
+	        this.severity = source["severity"];
+	        this.published = this.convertValues(source["published"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PreviousVersion {
+	    available: boolean;
+	    version: string;
+	    created: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PreviousVersion(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.available = source["available"];
+	        this.version = source["version"];
+	        this.created = this.convertValues(source["created"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProductConfig {
+	    id: string;
+	    displayName: string;
+	    description: string;
+	    version: string;
+	    backend: string;
+	    fallbackUrls: string[];
+	    installDir: string;
+	    executable: string;
+	    launchProfiles: LaunchProfile[];
+	    logo: string;
+	    bundlePublicKey: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProductConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.displayName = source["displayName"];
+	        this.description = source["description"];
+	        this.version = source["version"];
+	        this.backend = source["backend"];
+	        this.fallbackUrls = source["fallbackUrls"];
+	        this.installDir = source["installDir"];
+	        this.executable = source["executable"];
+	        this.launchProfiles = this.convertValues(source["launchProfiles"], LaunchProfile);
+	        this.logo = source["logo"];
+	        this.bundlePublicKey = source["bundlePublicKey"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProductInfo {
+	    id: string;
+	    displayName: string;
+	    description: string;
+	    version: string;
+	    logo: string;
+	    installDir: string;
+	    status: string;
+	    launchProfiles: LaunchProfile[];
+	    launchable: boolean;
+	    selected: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProductInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.displayName = source["displayName"];
+	        this.description = source["description"];
+	        this.version = source["version"];
+	        this.logo = source["logo"];
+	        this.installDir = source["installDir"];
+	        this.status = source["status"];
+	        this.launchProfiles = this.convertValues(source["launchProfiles"], LaunchProfile);
+	        this.launchable = source["launchable"];
+	        this.selected = source["selected"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TelemetryConfig {
+	    enabled: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TelemetryConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	    }
+	}
+	export class TelemetryInfo {
+	    available: boolean;
+	    enabled: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TelemetryInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.available = source["available"];
+	        this.enabled = source["enabled"];
+	    }
+	}
+	export class Theme {
+	    primary: string;
+	    primaryHover: string;
+	    secondary: string;
+	    secondaryHover: string;
+	    backgroundGradient: string[];
+	    textPrimary: string;
+	    textSecondary: string;
+	    success: string;
+	    error: string;
+	    info: string;
+	    cardBg: string;
+	    progressBg: string;
+	    disabled: string;
+	    disabledText: string;
+	    backgroundImage: string;
+	    font: string;
+	    fontUrl: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Theme(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.primary = source["primary"];
+	        this.primaryHover = source["primaryHover"];
+	        this.secondary = source["secondary"];
+	        this.secondaryHover = source["secondaryHover"];
+	        this.backgroundGradient = source["backgroundGradient"];
+	        this.textPrimary = source["textPrimary"];
+	        this.textSecondary = source["textSecondary"];
+	        this.success = source["success"];
+	        this.error = source["error"];
+	        this.info = source["info"];
+	        this.cardBg = source["cardBg"];
+	        this.progressBg = source["progressBg"];
+	        this.disabled = source["disabled"];
+	        this.disabledText = source["disabledText"];
+	        this.backgroundImage = source["backgroundImage"];
+	        this.font = source["font"];
+	        this.fontUrl = source["fontUrl"];
+	    }
+	}
+	export class UpdateCheckConfig {
+	    interval: string;
+	    maxBackoff: string;
+	    policy: string;
+	    disableEvents: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateCheckConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.interval = source["interval"];
+	        this.maxBackoff = source["maxBackoff"];
+	        this.policy = source["policy"];
+	        this.disableEvents = source["disableEvents"];
+	    }
+	}
+	export class VersionStatus {
+	    installed: string;
+	    latest: string;
+	    minimumVersion: string;
+	    mandatory: boolean;
+	    pinned: string;
+	    state: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new VersionStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.installed = source["installed"];
+	        this.latest = source["latest"];
+	        this.minimumVersion = source["minimumVersion"];
+	        this.mandatory = source["mandatory"];
+	        this.pinned = source["pinned"];
+	        this.state = source["state"];
 	    }
 	}
 
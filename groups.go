@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"ppatcher/updater"
+)
+
+// groupsFile stores the player's component selection next to .downloadmeta.
+const groupsFile = ".groups"
+
+// baseGroup holds every file that isn't part of an optional component.
+const baseGroup = "base"
+
+// GroupMeta is a component as described by the server manifest.
+type GroupMeta = updater.Group
+
+// GroupInfo is a component as shown to the player, including whether it is
+// currently selected for install.
+type GroupInfo struct {
+	GroupMeta
+	Selected bool `json:"selected"`
+}
+
+// groupSelection holds the components the player explicitly selected and
+// deselected. Components they never chose follow the server defaults.
+type groupSelection struct {
+	Groups     []string `json:"groups"`
+	Deselected []string `json:"deselected,omitempty"`
+}
+
+// loadGroupSelection returns the player's explicit choices by group ID.
+// Groups without an entry were never chosen.
+func (p *product) loadGroupSelection() map[string]bool {
+	choices := map[string]bool{}
+	data, err := os.ReadFile(p.path(groupsFile))
+	if err != nil {
+		return choices
+	}
+
+	var selection groupSelection
+	if err := json.Unmarshal(data, &selection); err != nil {
+		return choices
+	}
+
+	for _, id := range selection.Groups {
+		choices[id] = true
+	}
+	for _, id := range selection.Deselected {
+		choices[id] = false
+	}
+	return choices
+}
+
+// selectedGroups resolves which of the manifest's groups should be
+// installed: required groups always, then the player's choice, and the
+// server default for groups the player never chose, such as ones added
+// by a later release.
+func (p *product) selectedGroups(groups []GroupMeta) map[string]bool {
+	choices := p.loadGroupSelection()
+
+	selected := map[string]bool{baseGroup: true}
+	for _, group := range groups {
+		if choice, ok := choices[group.ID]; ok && !group.Required {
+			selected[group.ID] = choice
+		} else {
+			selected[group.ID] = group.Required || group.Default
+		}
+	}
+	return selected
+}
+
+// fileGroup returns the group a manifest entry belongs to.
+func fileGroup(file MetaForFile) string {
+	if file.Group == "" {
+		return baseGroup
+	}
+	return file.Group
+}
+
+// fetchSelectedFiles reads the release's manifest entry by entry and keeps
+// the files of the components the player selected. Manifests without groups
+// are kept whole.
+func (p *product) fetchSelectedFiles() (*MetaDataForFiles, error) {
+	r, err := p.client().OpenManifest(context.Background())
+	if err != nil {
+		logger.Error("fetching files meta failed", "product", p.config.ID, "err", err)
+		return nil, err
+	}
+	defer r.Close()
+
+	selected := p.selectedGroups(r.Groups)
+	filesMeta := &MetaDataForFiles{Groups: r.Groups, Blobs: r.Blobs}
+	for {
+		file, err := r.Next()
+		if err == io.EOF {
+			return filesMeta, nil
+		}
+		if err != nil {
+			logger.Error("reading files meta failed", "product", p.config.ID, "err", err)
+			return nil, err
+		}
+		if len(r.Groups) == 0 || selected[fileGroup(file)] {
+			filesMeta.Files = append(filesMeta.Files, file)
+		}
+	}
+}
+
+// selectedMeta computes the overall hash and size of the selected files, the
+// counterpart of the server's /meta for a partial install.
+func selectedMeta(files []MetaForFile) (MetaData, error) {
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+
+	return MetaData{Hash: updater.OverallHash(files), TotalSize: totalSize}, nil
+}
+
+// Groups lists the selected product's components with their sizes and
+// whether each is selected for install.
+func (a *App) Groups() ([]GroupInfo, error) {
+	p := a.current()
+	r, err := p.client().OpenManifest(context.Background())
+	if err != nil {
+		logger.Error("fetching files meta failed", "product", p.config.ID, "err", err)
+		return nil, err
+	}
+	// The groups come before the files, which aren't needed here.
+	r.Close()
+
+	selected := p.selectedGroups(r.Groups)
+	groups := make([]GroupInfo, 0, len(r.Groups))
+	for _, group := range r.Groups {
+		groups = append(groups, GroupInfo{GroupMeta: group, Selected: selected[group.ID]})
+	}
+	return groups, nil
+}
+
+// SetGroups persists the player's component selection for the selected
+// product and removes the files of components that were deselected.
+// Required components cannot be deselected. Call ManualUpdate afterwards to
+// download newly selected ones.
+func (a *App) SetGroups(ids []string) error {
+	p := a.current()
+	if p.rootDir() == "" {
+		return errNoInstallDir
+	}
+
+	r, err := p.client().OpenManifest(context.Background())
+	if err != nil {
+		logger.Error("fetching files meta failed", "product", p.config.ID, "err", err)
+		return err
+	}
+	defer r.Close()
+
+	known := map[string]bool{}
+	for _, group := range r.Groups {
+		known[group.ID] = true
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Errorf("unknown group %q", id)
+		}
+		wanted[id] = true
+	}
+
+	previous := p.selectedGroups(r.Groups)
+
+	// Only groups the player changed, or chose before, are recorded; the
+	// others keep following the server default. Choices for groups this
+	// release doesn't have are kept for when they come back, and required
+	// groups aren't a choice.
+	choices := p.loadGroupSelection()
+	for _, group := range r.Groups {
+		_, chosen := choices[group.ID]
+		if group.Required {
+			delete(choices, group.ID)
+		} else if chosen || wanted[group.ID] != previous[group.ID] {
+			choices[group.ID] = wanted[group.ID]
+		}
+	}
+	selection := groupSelection{Groups: []string{}}
+	for id, choice := range choices {
+		if choice {
+			selection.Groups = append(selection.Groups, id)
+		} else {
+			selection.Deselected = append(selection.Deselected, id)
+		}
+	}
+	sort.Strings(selection.Groups)
+	sort.Strings(selection.Deselected)
+
+	data, err := json.Marshal(selection)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.path(groupsFile), data, 0644); err != nil {
+		return err
+	}
+
+	current := p.selectedGroups(r.Groups)
+	for {
+		file, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			logger.Error("reading files meta failed", "product", p.config.ID, "err", err)
+			return err
+		}
+		group := fileGroup(file)
+		if !previous[group] || current[group] {
+			continue
+		}
+		if err := os.Remove(p.path(file.Path)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("removing deselected file failed", "product", p.config.ID, "path", file.Path, "err", err)
+		}
+	}
+}
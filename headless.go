@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ppatcher/updater"
+)
+
+// hasHeadlessFlag reports whether the command line asks for headless mode
+// with --headless.
+func hasHeadlessFlag(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && strings.TrimLeft(arg, "-") == "headless" {
+			return true
+		}
+	}
+	return false
+}
+
+// runHeadless updates every product once without opening a window, for
+// servers, CI jobs and scripts. Products that have no install directory yet
+// get the default one. Progress is printed to stdout, one line per file, and
+// the result is the exit code: 1 if a check or a file failed.
+func runHeadless() int {
+	app := NewApp()
+	code := 0
+	for _, p := range app.products {
+		p.loadToken()
+		p.setRoot(resolveInstallDir(p))
+		p.loadPin()
+		if p.rootDir() == "" {
+			if err := p.setInstallDir(defaultInstallDir(p)); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", p.config.ID, errorMessage(err))
+				code = 1
+				continue
+			}
+		}
+		if err := os.MkdirAll(p.rootDir(), os.ModePerm); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p.config.ID, errorMessage(err))
+			code = 1
+			continue
+		}
+
+		fmt.Printf("%s: updating %s\n", p.config.ID, p.rootDir())
+		p.sink = printEvents(p.config.ID)
+		if err := p.manualUpdate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p.config.ID, errorMessage(err))
+			code = 1
+			continue
+		}
+		if report := p.lastReport(); report != nil && len(report.Failed) > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d files failed to download\n", p.config.ID, len(report.Failed))
+			code = 1
+			continue
+		}
+		fmt.Printf("%s: %s\n", p.config.ID, T("status."+p.currentStatus()))
+	}
+	return code
+}
+
+// printEvents prints the files an update changed. Up-to-date files are left
+// out.
+func printEvents(id string) updater.SinkFunc {
+	return func(event updater.Event) {
+		switch event.Kind {
+		case updater.EventDownloaded:
+			fmt.Printf("%s: downloaded %s\n", id, event.File.Path)
+		case updater.EventRetrying:
+			fmt.Printf("%s: retrying %s (attempt %d): %v\n", id, event.File.Path, event.Attempt, event.Err)
+		case updater.EventFailed:
+			fmt.Printf("%s: failed %s: %v\n", id, event.File.Path, event.Err)
+		case updater.EventRemoved:
+			fmt.Printf("%s: removed %s\n", id, event.File.Path)
+		}
+	}
+}
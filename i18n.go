@@ -0,0 +1,320 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// localeFiles are the message catalogues shipped with the patcher, one flat
+// JSON object of keys to messages per language. Messages may contain
+// placeholders such as {version}.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// defaultLanguage is the complete catalogue other languages fall back to.
+const defaultLanguage = "en"
+
+// i18n holds the catalogues and the language of the UI. The catalogues are
+// the embedded ones with the config's and then the server's translations
+// merged on top.
+var i18n struct {
+	sync.RWMutex
+	catalogues map[string]map[string]string
+	language   string
+}
+
+// LanguageOption is a language the player can choose.
+type LanguageOption struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// initI18n loads the catalogues and picks the language: the player's
+// choice, then the config's language, then the OS locale.
+func initI18n() {
+	catalogues := embeddedCatalogues()
+	mergeCatalogues(catalogues, BuildConfig.Translations)
+
+	i18n.Lock()
+	defer i18n.Unlock()
+	i18n.catalogues = catalogues
+	i18n.language = pickLanguage(catalogues, loadSettings().Language)
+	logger.Info("language selected", "language", i18n.language)
+}
+
+// pickLanguage returns the first of the player's choice, the config's
+// language and the OS locale that has a catalogue.
+func pickLanguage(catalogues map[string]map[string]string, choice string) string {
+	for _, tag := range []string{choice, BuildConfig.Language, systemLocale()} {
+		if code := matchLanguage(catalogues, tag); code != "" {
+			return code
+		}
+	}
+	return defaultLanguage
+}
+
+func embeddedCatalogues() map[string]map[string]string {
+	catalogues := map[string]map[string]string{}
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		var catalogue map[string]string
+		if err := json.Unmarshal(data, &catalogue); err != nil {
+			logger.Error("invalid message catalogue", "file", entry.Name(), "err", err)
+			continue
+		}
+		catalogues[strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))] = catalogue
+	}
+	return catalogues
+}
+
+// mergeCatalogues merges translations into catalogues message by message.
+// Languages that only exist in translations are added.
+func mergeCatalogues(catalogues, translations map[string]map[string]string) {
+	for code, messages := range translations {
+		code = strings.ToLower(code)
+		if catalogues[code] == nil {
+			catalogues[code] = map[string]string{}
+		}
+		for key, message := range messages {
+			catalogues[code][key] = message
+		}
+	}
+}
+
+// knownLanguage reports whether code is shipped with the patcher or added
+// by the config's translations.
+func knownLanguage(config *Config, code string) bool {
+	catalogues := embeddedCatalogues()
+	mergeCatalogues(catalogues, config.Translations)
+	return matchLanguage(catalogues, code) != ""
+}
+
+// matchLanguage returns the catalogue for a locale such as "de_DE.UTF-8" or
+// "pt-BR": the exact language and region if there is one, otherwise the
+// language alone. It returns "" if there is neither.
+func matchLanguage(catalogues map[string]map[string]string, tag string) string {
+	tag, _, _ = strings.Cut(tag, ".")
+	tag, _, _ = strings.Cut(tag, "@")
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" || tag == "c" || tag == "posix" {
+		return ""
+	}
+	if _, ok := catalogues[tag]; ok {
+		return tag
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := catalogues[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// T returns the message for key in the UI language with the placeholders
+// filled in from name, value pairs.
+func T(key string, args ...interface{}) string {
+	i18n.RLock()
+	language := i18n.language
+	i18n.RUnlock()
+	return translate(language, key, args...)
+}
+
+// translate returns the message for key in the given language, falling back
+// to the language without its region, then to English and finally to the
+// key itself.
+func translate(language, key string, args ...interface{}) string {
+	i18n.RLock()
+	message, ok := i18n.catalogues[language][key]
+	if !ok {
+		base, _, _ := strings.Cut(language, "-")
+		message, ok = i18n.catalogues[base][key]
+	}
+	if !ok {
+		message, ok = i18n.catalogues[defaultLanguage][key]
+	}
+	i18n.RUnlock()
+	if !ok {
+		message = key
+	}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		message = strings.ReplaceAll(message, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return message
+}
+
+// Language returns the code of the UI language.
+func (a *App) Language() string {
+	i18n.RLock()
+	defer i18n.RUnlock()
+	return i18n.language
+}
+
+// Languages returns the languages the player can choose, by name.
+func (a *App) Languages() []LanguageOption {
+	i18n.RLock()
+	options := make([]LanguageOption, 0, len(i18n.catalogues))
+	for code, catalogue := range i18n.catalogues {
+		name := catalogue["language.name"]
+		if name == "" {
+			name = code
+		}
+		options = append(options, LanguageOption{Code: code, Name: name})
+	}
+	i18n.RUnlock()
+
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Name < options[j].Name
+	})
+	return options
+}
+
+// SetLanguage switches the UI to the language with the given code and
+// remembers it for future runs. An empty code goes back to the config's
+// language or the OS locale. The new messages are sent to the frontend with
+// a "languageChanged" event.
+func (a *App) SetLanguage(code string) error {
+	i18n.RLock()
+	language := matchLanguage(i18n.catalogues, code)
+	i18n.RUnlock()
+	if code != "" && language == "" {
+		return fmt.Errorf("unknown language %q", code)
+	}
+
+	s := loadSettings()
+	s.Language = language
+	if err := saveSettings(s); err != nil {
+		return err
+	}
+	i18n.Lock()
+	i18n.language = pickLanguage(i18n.catalogues, language)
+	i18n.Unlock()
+	logger.Info("language changed", "language", a.Language())
+	a.emitTranslations()
+	return nil
+}
+
+// Translations returns every message in the UI language, with English
+// filling the gaps of incomplete catalogues.
+func (a *App) Translations() map[string]string {
+	i18n.RLock()
+	defer i18n.RUnlock()
+	base, _, _ := strings.Cut(i18n.language, "-")
+	messages := map[string]string{}
+	for _, code := range []string{defaultLanguage, base, i18n.language} {
+		for key, message := range i18n.catalogues[code] {
+			messages[key] = message
+		}
+	}
+	return messages
+}
+
+func (a *App) emitTranslations() {
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, "languageChanged", a.Translations())
+}
+
+// fetchTranslations merges {backend}/translations into the catalogues, so
+// that wording can be changed without a new patcher build. A server without
+// translations answers 404, which is not an error.
+func (p *product) fetchTranslations() {
+	resp, err := p.get("/translations")
+	if err != nil {
+		logger.Debug("fetching translations failed", "product", p.config.ID, "err", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	var translations map[string]map[string]string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&translations); err != nil {
+		logger.Warn("invalid translations from server", "product", p.config.ID, "err", err)
+		return
+	}
+	i18n.Lock()
+	mergeCatalogues(i18n.catalogues, translations)
+	i18n.Unlock()
+	logger.Info("translations loaded", "product", p.config.ID, "languages", len(translations))
+	p.app.emitTranslations()
+}
+
+// localError is an error shown to the player. Its message is looked up in
+// the UI language each time it is read; logs get the English message.
+type localError struct {
+	key  string
+	args []interface{}
+	err  error
+}
+
+// newLocalError returns an error with the message for key, filled in from
+// name, value pairs as with T.
+func newLocalError(key string, args ...interface{}) error {
+	return &localError{key: key, args: args}
+}
+
+// wrapLocalError is newLocalError with err as the cause, which is appended
+// to the message.
+func wrapLocalError(err error, key string, args ...interface{}) error {
+	return &localError{key: key, args: args, err: err}
+}
+
+func (e *localError) Error() string {
+	return e.message(T(e.key, e.args...))
+}
+
+func (e *localError) message(text string) string {
+	if e.err != nil {
+		return text + ": " + e.err.Error()
+	}
+	return text
+}
+
+func (e *localError) Unwrap() error {
+	return e.err
+}
+
+// LogValue keeps logs in English whatever the UI language is.
+func (e *localError) LogValue() slog.Value {
+	return slog.StringValue(e.message(translate(defaultLanguage, e.key, e.args...)))
+}
+
+// errorMessage describes err to the player. Errors that weren't written for
+// players, such as network failures, are shown after a generic message.
+func errorMessage(err error) string {
+	var local *localError
+	if errors.As(err, &local) {
+		return err.Error()
+	}
+	return T("error.generic", "reason", err.Error())
+}
+
+// systemLocale returns the locale of the user's environment, such as
+// "de_DE.UTF-8" or "fr-FR", or "" if it can't be determined.
+func systemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return osLocale()
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	goRunTime "runtime"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ppatcher/updater"
+)
+
+// errNoInstallDir is returned by update operations before the player picked
+// an install location on first run.
+var errNoInstallDir = newLocalError("error.noInstallDir")
+
+// settings are per-user choices that outlive a single run of the patcher.
+type settings struct {
+	// InstallDir is the install root of the default product.
+	InstallDir string `json:"installDir"`
+	// InstallDirs holds the install roots of configured products by ID.
+	InstallDirs map[string]string `json:"installDirs,omitempty"`
+	// Language is the code of the language the player chose; empty follows
+	// the config and the OS locale.
+	Language string `json:"language,omitempty"`
+	// Telemetry is set when the player opted in to update reports, which
+	// are sent with InstallID.
+	Telemetry bool   `json:"telemetry,omitempty"`
+	InstallID string `json:"installId,omitempty"`
+	// RolloutID places the install on one side of the backend's staged
+	// rollouts. Unlike InstallID it is never sent with reports.
+	RolloutID string `json:"rolloutId,omitempty"`
+}
+
+// safeDirName replaces characters that aren't allowed in directory names.
+func safeDirName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// appDirName returns a filesystem-safe directory name for per-user data.
+func appDirName() string {
+	name := strings.TrimSpace(BuildConfig.Title)
+	if name == "" {
+		name = DefaultTitle
+	}
+	return safeDirName(name)
+}
+
+// settingsPath returns the location of the per-user settings file.
+func settingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName(), "settings.json"), nil
+}
+
+func loadSettings() settings {
+	var s settings
+	path, err := settingsPath()
+	if err != nil {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	json.Unmarshal(data, &s)
+	return s
+}
+
+func saveSettings(s settings) error {
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+var (
+	rolloutIDOnce  sync.Once
+	rolloutIDCache string
+)
+
+// rolloutID returns the install's rollout ID, creating and saving it on
+// first use. It is "" if no ID could be created, which keeps the install
+// on the current release.
+func rolloutID() string {
+	rolloutIDOnce.Do(func() {
+		s := loadSettings()
+		if s.RolloutID == "" {
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return
+			}
+			s.RolloutID = hex.EncodeToString(id)
+			if err := saveSettings(s); err != nil {
+				logger.Warn("saving rollout ID failed", "err", err)
+				return
+			}
+		}
+		rolloutIDCache = s.RolloutID
+	})
+	return rolloutIDCache
+}
+
+// savedInstallDir returns the install root the player chose for a product.
+func savedInstallDir(id string) string {
+	s := loadSettings()
+	if id == defaultProductID {
+		return s.InstallDir
+	}
+	return s.InstallDirs[id]
+}
+
+// saveInstallDir remembers the install root of a product.
+func saveInstallDir(id, dir string) error {
+	s := loadSettings()
+	if id == defaultProductID {
+		s.InstallDir = dir
+	} else {
+		if s.InstallDirs == nil {
+			s.InstallDirs = map[string]string{}
+		}
+		s.InstallDirs[id] = dir
+	}
+	return saveSettings(s)
+}
+
+// defaultInstallDir returns the platform's conventional per-user location
+// for a product's files.
+func defaultInstallDir(p *product) string {
+	var base string
+	switch goRunTime.GOOS {
+	case "windows":
+		base = os.Getenv("LOCALAPPDATA")
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			base = filepath.Join(home, "Library", "Application Support")
+		}
+	default:
+		base = os.Getenv("XDG_DATA_HOME")
+		if base == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				base = filepath.Join(home, ".local", "share")
+			}
+		}
+	}
+	if base == "" {
+		base = os.TempDir()
+	}
+	if p.config.ID == defaultProductID {
+		return filepath.Join(base, appDirName())
+	}
+	return filepath.Join(base, appDirName(), safeDirName(p.config.ID))
+}
+
+// expandInstallDir expands "~" and environment variables in a configured
+// install path. Relative paths are resolved against the patcher's directory.
+func expandInstallDir(dir, exeDir string) string {
+	dir = os.ExpandEnv(strings.TrimSpace(dir))
+	if dir == "~" || strings.HasPrefix(dir, "~/") || strings.HasPrefix(dir, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[1:])
+		}
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(exeDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// resolveInstallDir picks a product's install root for this run: the
+// working directory in dev mode, then the configured location, then the
+// player's saved choice, then the patcher's own directory for installs that
+// predate configurable locations. It returns "" on first run.
+func resolveInstallDir(p *product) string {
+	if BuildConfig.Mode == "dev" {
+		if cwd, err := os.Getwd(); err == nil {
+			if p.config.ID == defaultProductID {
+				return cwd
+			}
+			return filepath.Join(cwd, safeDirName(p.config.ID))
+		}
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		log.Fatal("Could not get executable path: ", err)
+	}
+	exeDir := filepath.Dir(exePath)
+
+	if p.config.InstallDir != "" {
+		return expandInstallDir(p.config.InstallDir, exeDir)
+	}
+
+	if dir := savedInstallDir(p.config.ID); dir != "" {
+		return dir
+	}
+
+	if p.config.ID == defaultProductID {
+		if _, err := os.Stat(filepath.Join(exeDir, ".downloadmeta")); err == nil {
+			saveInstallDir(p.config.ID, exeDir)
+			return exeDir
+		}
+	}
+
+	return ""
+}
+
+// checkWritable makes sure dir exists and files can be created in it.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".ppatcher-write-test-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// path resolves a slash-separated manifest path against the install root.
+func (p *product) path(rel string) string {
+	return filepath.Join(p.rootDir(), filepath.FromSlash(rel))
+}
+
+// InstallDir returns the selected product's install root, or "" if the
+// player still has to pick one.
+func (a *App) InstallDir() string {
+	return a.current().rootDir()
+}
+
+// DefaultInstallDir returns the suggested install location for first run.
+func (a *App) DefaultInstallDir() string {
+	return defaultInstallDir(a.current())
+}
+
+// ChooseInstallDir opens a native directory picker and returns the chosen
+// directory without applying it. It returns "" if the dialog was cancelled.
+func (a *App) ChooseInstallDir() (string, error) {
+	defaultDir := a.current().rootDir()
+	if defaultDir == "" {
+		defaultDir = filepath.Dir(defaultInstallDir(a.current()))
+	}
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                T("installDir.dialogTitle"),
+		DefaultDirectory:     defaultDir,
+		CanCreateDirectories: true,
+	})
+}
+
+// SetInstallDir makes dir the selected product's install root and remembers
+// it for future runs. Existing files are not moved; use MoveInstall for that.
+func (a *App) SetInstallDir(dir string) error {
+	return a.current().setInstallDir(dir)
+}
+
+func (p *product) setInstallDir(dir string) error {
+	if p.config.InstallDir != "" {
+		return newLocalError("error.installDirFixed")
+	}
+	dir = filepath.Clean(dir)
+	if !filepath.IsAbs(dir) {
+		return newLocalError("error.installDirRelative")
+	}
+	if err := checkWritable(dir); err != nil {
+		return wrapLocalError(err, "error.installDirNotWritable")
+	}
+	if err := saveInstallDir(p.config.ID, dir); err != nil {
+		return err
+	}
+	p.setRoot(dir)
+	p.loadPin()
+	return nil
+}
+
+// MoveInstall moves the selected product's install to dir, which must be
+// empty, and makes it the new root. Only the installed files and the
+// patcher's own metadata are moved, so other files in the old root, such as
+// the running patcher executable, stay where they are.
+func (a *App) MoveInstall(dir string) error {
+	return a.current().moveInstall(dir)
+}
+
+func (p *product) moveInstall(dir string) error {
+	oldRoot := p.rootDir()
+	if oldRoot == "" {
+		return errNoInstallDir
+	}
+	if !p.updating.TryLock() {
+		return errUpdateInProgress
+	}
+	defer p.updating.Unlock()
+
+	dir = filepath.Clean(dir)
+	if dir == oldRoot {
+		return nil
+	}
+	if rel, err := filepath.Rel(oldRoot, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return newLocalError("error.installDirInside")
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return newLocalError("error.installDirNotEmpty")
+	}
+	if err := checkWritable(dir); err != nil {
+		return wrapLocalError(err, "error.installDirNotWritable")
+	}
+
+	paths, err := p.installPaths()
+	if err != nil {
+		return err
+	}
+
+	var moved []string
+	// undo puts the files already moved back, so that a failed move
+	// leaves the install where it was.
+	undo := func() {
+		for i := len(moved) - 1; i >= 0; i-- {
+			from := filepath.Join(dir, moved[i])
+			if err := moveFile(from, filepath.Join(oldRoot, moved[i])); err != nil {
+				logger.Error("moving file back failed", "product", p.config.ID, "path", from, "err", err)
+				continue
+			}
+			removeEmptyParents(dir, moved[i])
+		}
+	}
+
+	for _, rel := range paths {
+		if err := moveFile(filepath.Join(oldRoot, rel), filepath.Join(dir, rel)); err != nil {
+			undo()
+			return err
+		}
+		moved = append(moved, rel)
+	}
+
+	if err := p.setInstallDir(dir); err != nil {
+		undo()
+		return err
+	}
+	for _, rel := range moved {
+		removeEmptyParents(oldRoot, rel)
+	}
+	os.Remove(oldRoot)
+	logger.Info("moved install", "product", p.config.ID, "from", oldRoot, "to", dir, "files", len(moved))
+	return nil
+}
+
+// installPaths returns the files that make up the install, relative to its
+// root: the installed files that exist, the patcher's metadata and the
+// backups. Installs from before the installed manifest was recorded use
+// the selected files of the current release.
+func (p *product) installPaths() ([]string, error) {
+	files := p.loadInstalledFiles()
+	if files == nil {
+		filesMeta, err := p.fetchSelectedFiles()
+		if err != nil {
+			return nil, err
+		}
+		files = filesMeta.Files
+	}
+
+	root := p.rootDir()
+	var paths []string
+	seen := map[string]bool{}
+	add := func(rel string) {
+		rel = filepath.FromSlash(rel)
+		if seen[rel] {
+			return
+		}
+		if info, err := os.Lstat(filepath.Join(root, rel)); err == nil && info.Mode().IsRegular() {
+			seen[rel] = true
+			paths = append(paths, rel)
+		}
+	}
+	for _, file := range files {
+		if updater.SafePath(file.Path) {
+			add(file.Path)
+		}
+	}
+	add(".downloadmeta")
+	add(installedFilesFile)
+	add(groupsFile)
+
+	backups := p.path(backupDir)
+	err := filepath.WalkDir(backups, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			add(rel)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// moveFile renames src to dst, falling back to copy and delete across
+// filesystems. It refuses to replace an existing dst.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.PathError{Op: "move", Path: dst, Err: os.ErrExist}
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// removeEmptyParents deletes the directories between root and the file rel
+// that are left empty, deepest first. root itself is kept.
+func removeEmptyParents(root, rel string) {
+	for dir := filepath.Dir(rel); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if os.Remove(filepath.Join(root, dir)) != nil {
+			return
+		}
+	}
+}
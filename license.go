@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ppatcher/updater"
+)
+
+// errLicenseRequired matches every licenseError.
+var errLicenseRequired = updater.ErrLicenseRequired
+
+// licenseError is returned when the backend refuses a request for lack of a
+// valid license token. Message is the server's reason.
+type licenseError = updater.AuthError
+
+// ActivationInfo is the license state of a product as shown to the
+// frontend.
+type ActivationInfo struct {
+	// Required is set once the backend asked for a license.
+	Required  bool       `json:"required"`
+	Activated bool       `json:"activated"`
+	Name      string     `json:"name"`
+	Expires   *time.Time `json:"expires"`
+	// Error is the backend's reason for refusing the stored token, if any.
+	Error string `json:"error"`
+}
+
+// licenseAccount is the secret store account holding a product's token.
+func (p *product) licenseAccount() string {
+	return "license-" + p.config.ID
+}
+
+// loadToken reads the product's license token from the secret store.
+func (p *product) loadToken() {
+	token, err := loadSecret(p.licenseAccount())
+	if err != nil {
+		logger.Warn("reading license token failed", "product", p.config.ID, "err", err)
+		return
+	}
+	p.mu.Lock()
+	p.token = token
+	p.activation.Activated = token != ""
+	p.mu.Unlock()
+}
+
+// requireActivation records that the backend refused the product's token
+// and asks the frontend for a license key.
+func (p *product) requireActivation(err error) {
+	p.mu.Lock()
+	p.activation.Required = true
+	p.activation.Error = err.Error()
+	activation := p.activation
+	p.mu.Unlock()
+	logger.Warn("license required", "product", p.config.ID, "err", err)
+	p.setStatus("activationRequired")
+	p.emit("activationRequired", activation)
+}
+
+// activationInfo returns the product's license state.
+func (p *product) activationInfo() ActivationInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.activation
+}
+
+// Activation returns the license state of the selected product.
+func (a *App) Activation() ActivationInfo {
+	return a.current().activationInfo()
+}
+
+// Activate exchanges a license key for an access token for the selected
+// product and stores the token in the OS credential store.
+func (a *App) Activate(key string) (ActivationInfo, error) {
+	return a.current().activate(key)
+}
+
+func (p *product) activate(key string) (ActivationInfo, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return p.activationInfo(), newLocalError("error.enterLicenseKey")
+	}
+	body, err := json.Marshal(map[string]string{"key": key})
+	if err != nil {
+		return p.activationInfo(), err
+	}
+
+	resp, err := p.post("/activate", body)
+	if err != nil {
+		return p.activationInfo(), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var payload struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&payload)
+		if payload.Error == "" {
+			payload.Error = fmt.Sprintf("status code %d", resp.StatusCode)
+		}
+		logger.Warn("activation failed", "product", p.config.ID, "err", payload.Error)
+		return p.activationInfo(), errors.New(payload.Error)
+	}
+
+	var payload struct {
+		Token   string     `json:"token"`
+		Name    string     `json:"name"`
+		Expires *time.Time `json:"expires"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.Token == "" {
+		return p.activationInfo(), newLocalError("error.invalidActivation")
+	}
+	if err := saveSecret(p.licenseAccount(), payload.Token); err != nil {
+		logger.Error("storing license token failed", "product", p.config.ID, "err", err)
+		return p.activationInfo(), wrapLocalError(err, "error.storeLicense")
+	}
+
+	activation := ActivationInfo{
+		Required:  true,
+		Activated: true,
+		Name:      payload.Name,
+		Expires:   payload.Expires,
+	}
+	p.mu.Lock()
+	p.token = payload.Token
+	p.activation = activation
+	p.mu.Unlock()
+	logger.Info("license activated", "product", p.config.ID, "name", payload.Name)
+	return activation, nil
+}
+
+// Deactivate removes the selected product's license token from this
+// machine.
+func (a *App) Deactivate() error {
+	p := a.current()
+	if err := deleteSecret(p.licenseAccount()); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.token = ""
+	p.activation = ActivationInfo{Required: p.activation.Required}
+	p.mu.Unlock()
+	logger.Info("license deactivated", "product", p.config.ID)
+	return nil
+}
+
+// post sends a JSON body to path on the product's backend, trying fallback
+// URLs like get.
+func (p *product) post(path string, body []byte) (*http.Response, error) {
+	return p.request(http.MethodPost, path, func() io.Reader { return bytes.NewReader(body) }, 0)
+}
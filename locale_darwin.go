@@ -0,0 +1,18 @@
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// osLocale returns the user's locale from the system preferences, such as
+// "de_DE". Apps started from the Finder don't get LANG.
+func osLocale() string {
+	out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
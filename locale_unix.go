@@ -0,0 +1,9 @@
+//go:build !windows && !darwin
+
+package main
+
+// osLocale returns "": on Linux and BSD the locale only comes from the
+// environment, which systemLocale already checked.
+func osLocale() string {
+	return ""
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetUserDefaultLocaleName = kernel32.NewProc("GetUserDefaultLocaleName")
+
+// localeNameMaxLength is LOCALE_NAME_MAX_LENGTH.
+const localeNameMaxLength = 85
+
+// osLocale returns the user's display locale, such as "de-DE".
+func osLocale() string {
+	buf := make([]uint16, localeNameMaxLength)
+	r, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
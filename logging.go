@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	goRunTime "runtime"
+)
+
+const (
+	// logFileName is the name of the active log file in the log directory.
+	// Rotated files get a numeric suffix: patcher.log.1 is the newest.
+	logFileName = "patcher.log"
+	// maxLogSize is the size at which the log file is rotated.
+	maxLogSize = 5 * 1024 * 1024
+	// maxLogBackups is the number of rotated log files kept.
+	maxLogBackups = 5
+)
+
+// logger is the patcher's structured logger. It discards everything until
+// initLogging has run.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// logDir returns the platform's conventional per-user log directory.
+func logDir() (string, error) {
+	switch goRunTime.GOOS {
+	case "windows":
+		if base := os.Getenv("LOCALAPPDATA"); base != "" {
+			return filepath.Join(base, appDirName(), "logs"), nil
+		}
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, "Library", "Logs", appDirName()), nil
+		}
+	default:
+		if base := os.Getenv("XDG_STATE_HOME"); base != "" {
+			return filepath.Join(base, appDirName(), "logs"), nil
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "state", appDirName(), "logs"), nil
+		}
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName(), "logs"), nil
+}
+
+// logLevel returns the verbosity for the configured mode: everything in dev
+// builds, informational messages and above in production.
+func logLevel() slog.Level {
+	if BuildConfig.Mode == "production" {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// initLogging sets up logger to write JSON lines to a rotating file in the
+// log directory, and to stderr as well outside production. Output of the
+// standard log package is routed through it. If the log file can't be
+// opened, logging continues on stderr only.
+func initLogging() {
+	var writers []io.Writer
+	if BuildConfig.Mode != "production" {
+		writers = append(writers, os.Stderr)
+	}
+
+	var fileErr error
+	dir, err := logDir()
+	if err == nil {
+		var file *rotatingFile
+		file, err = openRotatingFile(filepath.Join(dir, logFileName), maxLogSize, maxLogBackups)
+		if err == nil {
+			writers = append(writers, file)
+		}
+	}
+	fileErr = err
+
+	if len(writers) == 0 {
+		writers = append(writers, os.Stderr)
+	}
+
+	logger = slog.New(slog.NewJSONHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: logLevel()}))
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+
+	if fileErr != nil {
+		logger.Warn("could not open log file", "err", fileErr)
+	}
+	logger.Info("patcher started",
+		"version", BuildConfig.Version,
+		"mode", BuildConfig.Mode,
+		"os", goRunTime.GOOS,
+		"arch", goRunTime.GOARCH,
+	)
+}
+
+// rotatingFile is an io.Writer that appends to a file and rotates it once it
+// grows beyond maxSize, keeping at most backups old files.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size+int64(len(b)) > r.maxSize && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts patcher.log.N to patcher.log.N+1, dropping the oldest, and
+// starts a new log file.
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.backups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
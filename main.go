@@ -3,6 +3,7 @@ package main
 import (
 	"embed"
 	"log"
+	"os"
 
 	"github.com/kbinani/screenshot"
 	"github.com/wailsapp/wails/v2"
@@ -15,6 +16,14 @@ var assets embed.FS
 
 func main() {
 	InitConfig()
+	initLogging()
+	logConfigReport()
+	initI18n()
+	initNetwork()
+
+	if hasHeadlessFlag(os.Args[1:]) {
+		os.Exit(runHeadless())
+	}
 
 	bounds := screenshot.GetDisplayBounds(0)
 	screenWidth := bounds.Dx()
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// renderMarkdown converts the Markdown subset used for news and changelogs
+// (headings, paragraphs, lists, quotes, code, emphasis and links) to HTML
+// that is safe to inject into the frontend. All input is escaped first, so
+// the only markup in the result is the one generated here; link targets are
+// restricted to http, https and mailto.
+func renderMarkdown(src string) string {
+	var out strings.Builder
+	var paragraph []string
+	list := ""
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, " ")) + "</p>")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out.WriteString("</" + list + ">")
+			list = ""
+		}
+	}
+	openList := func(tag string) {
+		if list != tag {
+			closeList()
+			out.WriteString("<" + tag + ">")
+			list = tag
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				out.WriteString("</code></pre>")
+			} else {
+				flushParagraph()
+				closeList()
+				out.WriteString("<pre><code>")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+		case headingPattern.MatchString(trimmed):
+			flushParagraph()
+			closeList()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			out.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">")
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			flushParagraph()
+			openList("ul")
+			out.WriteString("<li>" + renderInline(trimmed[2:]) + "</li>")
+		case orderedPattern.MatchString(trimmed):
+			flushParagraph()
+			openList("ol")
+			out.WriteString("<li>" + renderInline(orderedPattern.ReplaceAllString(trimmed, "")) + "</li>")
+		case strings.HasPrefix(trimmed, ">"):
+			flushParagraph()
+			closeList()
+			out.WriteString("<blockquote>" + renderInline(strings.TrimSpace(trimmed[1:])) + "</blockquote>")
+		default:
+			closeList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+
+	if inCode {
+		out.WriteString("</code></pre>")
+	}
+	flushParagraph()
+	closeList()
+
+	return out.String()
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	orderedPattern = regexp.MustCompile(`^\d+[.)]\s+`)
+	linkPattern    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldPattern    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	italicPattern  = regexp.MustCompile(`(^|[^\w*])[*_]([^*_]+)[*_]`)
+)
+
+// renderInline escapes text and applies code spans, links and emphasis.
+func renderInline(text string) string {
+	var out strings.Builder
+	// Odd segments are inside backticks and are rendered verbatim.
+	for i, segment := range strings.Split(text, "`") {
+		escaped := html.EscapeString(segment)
+		if i%2 == 1 {
+			out.WriteString("<code>" + escaped + "</code>")
+			continue
+		}
+		escaped = linkPattern.ReplaceAllStringFunc(escaped, func(match string) string {
+			m := linkPattern.FindStringSubmatch(match)
+			target := html.UnescapeString(m[2])
+			lower := strings.ToLower(target)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "mailto:") {
+				return m[1]
+			}
+			return `<a href="` + html.EscapeString(target) + `" target="_blank" rel="noopener noreferrer">` + m[1] + "</a>"
+		})
+		escaped = boldPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = italicPattern.ReplaceAllString(escaped, "$1<em>$2</em>")
+		out.WriteString(escaped)
+	}
+	return out.String()
+}
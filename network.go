@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults for the network timeouts in Config.Network.
+const (
+	defaultConnectTimeout = 15 * time.Second
+	defaultIdleTimeout    = 30 * time.Second
+	defaultRequestTimeout = 60 * time.Second
+)
+
+// NetworkConfig controls how the patcher talks to its backends. Durations
+// use Go syntax such as "15s" or "2m".
+type NetworkConfig struct {
+	// ConnectTimeout limits dialing and the TLS handshake.
+	ConnectTimeout string `json:"connectTimeout"`
+	// IdleTimeout is how long a response may stall without receiving data,
+	// including the wait for response headers.
+	IdleTimeout string `json:"idleTimeout"`
+	// RequestTimeout limits whole API requests such as /meta and /version.
+	// File downloads are only bounded by IdleTimeout, so large files on
+	// slow connections still complete.
+	RequestTimeout string `json:"requestTimeout"`
+	// Proxy is a proxy URL, "direct" to bypass proxies, or empty to use the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `json:"proxy"`
+	// CABundle is a PEM file with extra trusted certificate authorities,
+	// relative to the patcher's directory unless absolute.
+	CABundle string `json:"caBundle"`
+	// Pins are base64 SHA-256 hashes of the SubjectPublicKeyInfo of
+	// certificates accepted for the backends, optionally prefixed with
+	// "sha256/". When set, a backend connection must present at least one
+	// pinned key in its verified chain.
+	Pins []string `json:"pins"`
+	// MinDownloads and MaxDownloads bound the number of files downloaded
+	// at once. The patcher adapts between them to the measured throughput.
+	MinDownloads int `json:"minDownloads"`
+	MaxDownloads int `json:"maxDownloads"`
+	// BatchThreshold is the size in bytes below which files are downloaded
+	// together through /batch instead of one request each. Zero uses 64 KiB,
+	// a negative value turns batching off.
+	BatchThreshold int64 `json:"batchThreshold"`
+}
+
+var (
+	// apiClient is used for small API requests and has an overall timeout.
+	apiClient = http.DefaultClient
+	// downloadClient is used for file payloads. Its responses fail once they
+	// stall for longer than the idle timeout.
+	downloadClient = http.DefaultClient
+	// downloadIdleTimeout is the idle timeout applied to download bodies.
+	downloadIdleTimeout = defaultIdleTimeout
+)
+
+// initNetwork builds the shared HTTP clients from BuildConfig.Network. If
+// the network settings are invalid every request fails with the reason, so
+// that a broken pin or CA bundle never silently falls back to defaults.
+func initNetwork() {
+	transport, timeouts, err := newTransport(BuildConfig.Network, pinnedHosts())
+	if err != nil {
+		logger.Error("invalid network configuration", "err", err)
+		failing := &http.Client{Transport: failingTransport{fmt.Errorf("invalid network configuration: %w", err)}}
+		apiClient, downloadClient = failing, failing
+		return
+	}
+	apiClient = &http.Client{Transport: transport, Timeout: timeouts.request}
+	downloadClient = &http.Client{Transport: transport}
+	downloadIdleTimeout = timeouts.idle
+}
+
+type networkTimeouts struct {
+	connect, idle, request time.Duration
+}
+
+func parseTimeout(name, value string, fallback time.Duration) (time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q", name, value)
+	}
+	return d, nil
+}
+
+// newTransport returns a transport for config. Pins are only enforced for
+// connections to the given hosts.
+func newTransport(config NetworkConfig, hosts map[string]bool) (*http.Transport, networkTimeouts, error) {
+	var timeouts networkTimeouts
+	var err error
+	if timeouts.connect, err = parseTimeout("connectTimeout", config.ConnectTimeout, defaultConnectTimeout); err != nil {
+		return nil, timeouts, err
+	}
+	if timeouts.idle, err = parseTimeout("idleTimeout", config.IdleTimeout, defaultIdleTimeout); err != nil {
+		return nil, timeouts, err
+	}
+	if timeouts.request, err = parseTimeout("requestTimeout", config.RequestTimeout, defaultRequestTimeout); err != nil {
+		return nil, timeouts, err
+	}
+
+	proxy, err := proxyFunc(config.Proxy)
+	if err != nil {
+		return nil, timeouts, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CABundle != "" {
+		pool, err := certPool(config.CABundle)
+		if err != nil {
+			return nil, timeouts, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(config.Pins) > 0 {
+		pins, err := parsePins(config.Pins)
+		if err != nil {
+			return nil, timeouts, err
+		}
+		tlsConfig.VerifyConnection = verifyPins(pins, hosts)
+	}
+
+	dialer := &net.Dialer{Timeout: timeouts.connect, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeouts.connect,
+		ResponseHeaderTimeout: timeouts.idle,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		// Every download worker can keep its connection alive between
+		// files. Over HTTP/2 they share one connection instead.
+		MaxIdleConnsPerHost: max(16, config.MaxDownloads),
+		ForceAttemptHTTP2:   true,
+	}
+	return transport, timeouts, nil
+}
+
+// proxyFunc resolves the proxy setting of the network config.
+func proxyFunc(setting string) (func(*http.Request) (*url.URL, error), error) {
+	setting = strings.TrimSpace(setting)
+	switch setting {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "direct", "none":
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(setting)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy: invalid URL %q", setting)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// certPool returns the system roots plus the certificates in the PEM file.
+func certPool(bundle string) (*x509.CertPool, error) {
+	if !filepath.IsAbs(bundle) {
+		if exePath, err := os.Executable(); err == nil {
+			bundle = filepath.Join(filepath.Dir(exePath), bundle)
+		}
+	}
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("caBundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("caBundle: no certificates found in %s", bundle)
+	}
+	return pool, nil
+}
+
+func parsePins(values []string) (map[string]bool, error) {
+	pins := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "sha256/")
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("pins: %q is not a base64 SHA-256 hash", value)
+		}
+		pins[value] = true
+	}
+	return pins, nil
+}
+
+// errPinMismatch is returned when a backend presents no pinned key.
+var errPinMismatch = errors.New("certificate does not match any pinned key")
+
+// verifyPins checks that a connection to one of hosts has a pinned key
+// somewhere in its verified chain. It runs after the normal certificate
+// verification. Connections without a server name (IP addresses) are always
+// checked.
+func verifyPins(pins, hosts map[string]bool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if cs.ServerName != "" && !hosts[strings.ToLower(cs.ServerName)] {
+			return nil
+		}
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[base64.StdEncoding.EncodeToString(sum[:])] {
+					return nil
+				}
+			}
+		}
+		return errPinMismatch
+	}
+}
+
+// pinnedHosts returns the hostnames of every configured backend and
+// fallback URL.
+func pinnedHosts() map[string]bool {
+	hosts := map[string]bool{}
+	for _, config := range productConfigs() {
+		for _, backend := range append([]string{config.Backend}, config.FallbackURLs...) {
+			if u, err := url.Parse(backend); err == nil && u.Hostname() != "" {
+				hosts[strings.ToLower(u.Hostname())] = true
+			}
+		}
+	}
+	return hosts
+}
+
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// NewsItem is an announcement from the backend. HTML is the sanitised
+// rendering of the Markdown Body.
+type NewsItem struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	HTML      string    `json:"html"`
+	Severity  string    `json:"severity"`
+	Published time.Time `json:"published"`
+}
+
+// NewsFeed is the backend's news feed together with the changelog of the
+// current release.
+type NewsFeed struct {
+	Items         []NewsItem `json:"items"`
+	Version       string     `json:"version"`
+	Changelog     string     `json:"changelog"`
+	ChangelogHTML string     `json:"changelogHtml"`
+}
+
+// fetchNews queries {backend}/news, stores the feed and emits a "news" event.
+// Backends without a news endpoint simply leave the feed empty.
+func (p *product) fetchNews() {
+	resp, err := p.get("/news")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	var feed NewsFeed
+	if err := json.Unmarshal(body, &feed); err != nil {
+		logger.Warn("parsing news feed failed", "product", p.config.ID, "err", err)
+		return
+	}
+
+	for i := range feed.Items {
+		feed.Items[i].HTML = renderMarkdown(feed.Items[i].Body)
+	}
+	feed.ChangelogHTML = renderMarkdown(feed.Changelog)
+
+	p.mu.Lock()
+	p.news = feed
+	p.mu.Unlock()
+	p.emit("news", feed)
+}
+
+// News returns the latest news feed of the selected product.
+func (a *App) News() NewsFeed {
+	return a.current().newsFeed()
+}
+
+// ProductNews returns the latest news feed of the product with the given ID.
+func (a *App) ProductNews(id string) (NewsFeed, error) {
+	p := a.product(id)
+	if p == nil {
+		return NewsFeed{}, fmt.Errorf("unknown product %q", id)
+	}
+	return p.newsFeed(), nil
+}
+
+// newsFeed returns the last news feed fetched for the product.
+func (p *product) newsFeed() NewsFeed {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.news
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// AvailableVersion is a release the backend keeps for InstallVersion.
+type AvailableVersion struct {
+	Version   string    `json:"version"`
+	TotalSize int64     `json:"totalSize"`
+	Created   time.Time `json:"created"`
+	// Latest marks the backend's current release.
+	Latest    bool `json:"latest"`
+	Installed bool `json:"installed"`
+	Pinned    bool `json:"pinned"`
+}
+
+// loadPin reads the pinned version from .downloadmeta.
+func (p *product) loadPin() {
+	p.setPinned("")
+	if p.rootDir() == "" {
+		return
+	}
+	data, err := os.ReadFile(p.path(".downloadmeta"))
+	if err != nil {
+		return
+	}
+	var meta MetaData
+	json.Unmarshal(data, &meta)
+	p.setPinned(meta.Pinned)
+	if meta.Pinned != "" {
+		logger.Info("install is pinned", "product", p.config.ID, "version", meta.Pinned)
+	}
+}
+
+// releasePath returns the backend path of a manifest or payload: the
+// current release's, or the archived one's while a version is pinned.
+func (p *product) releasePath(path string) string {
+	pinned := p.pinnedVersion()
+	if pinned == "" {
+		return path
+	}
+	return "/versions/" + url.PathEscape(pinned) + path
+}
+
+// AvailableVersions lists the releases the selected product's backend
+// keeps, newest first.
+func (a *App) AvailableVersions() ([]AvailableVersion, error) {
+	return a.current().availableVersions()
+}
+
+func (p *product) availableVersions() ([]AvailableVersion, error) {
+	resp, err := p.get("/versions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, newLocalError("error.versionsUnsupported")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Versions []struct {
+			Version   string    `json:"version"`
+			TotalSize int64     `json:"totalSize"`
+			Created   time.Time `json:"created"`
+			Current   bool      `json:"current"`
+		} `json:"versions"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	installed := p.installedVersion()
+	pinned := p.pinnedVersion()
+	versions := make([]AvailableVersion, 0, len(payload.Versions))
+	for _, v := range payload.Versions {
+		versions = append(versions, AvailableVersion{
+			Version:   v.Version,
+			TotalSize: v.TotalSize,
+			Created:   v.Created,
+			Latest:    v.Current,
+			Installed: v.Version == installed,
+			Pinned:    v.Version == pinned,
+		})
+	}
+	return versions, nil
+}
+
+// InstallVersion installs the given release of the selected product,
+// downgrading if it is older, and pins the install to it: background checks
+// leave it alone, and checks and repairs use that release until another
+// version is installed. An empty version removes the pin and installs the
+// latest release.
+func (a *App) InstallVersion(version string) error {
+	return a.current().installVersion(version)
+}
+
+func (p *product) installVersion(version string) (err error) {
+	if p.rootDir() == "" {
+		return errNoInstallDir
+	}
+	if version != "" {
+		p.fetchRemoteVersion()
+		if p.belowMinimum(version) {
+			minimum, _ := p.versionPolicy()
+			return newLocalError("error.versionTooOld", "version", version, "minimum", minimum)
+		}
+		versions, err := p.availableVersions()
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, v := range versions {
+			found = found || v.Version == version
+		}
+		if !found {
+			return newLocalError("error.versionNotFound", "version", version)
+		}
+	}
+	if !p.updating.TryLock() {
+		return errUpdateInProgress
+	}
+	defer p.updating.Unlock()
+
+	// The pin is only written to .downloadmeta once the files match it.
+	previous := p.pinnedVersion()
+	p.setPinned(version)
+	defer func() {
+		if err != nil {
+			p.setPinned(previous)
+			p.emitVersionStatus()
+		}
+	}()
+	logger.Info("installing version", "product", p.config.ID, "version", version, "previous", previous)
+
+	rel := &release{p: p}
+	if err := p.generateMetaFile(rel); err != nil {
+		logger.Error("generating local meta file failed", "product", p.config.ID, "err", err)
+		return err
+	}
+	return p.tryUpdating(rel)
+}
@@ -0,0 +1,291 @@
+// Command ppbundle packages a release, or the delta between two releases,
+// into a signed .ppatch file that patchers can import without a backend.
+//
+//	ppbundle keygen -out bundle.key
+//	ppbundle create -files ./files -key bundle.key -version 1.4.0 -out game-1.4.0.ppatch
+//	ppbundle create -files ./files -from ./files-1.3.0 -key bundle.key -version 1.4.0 -out game-1.3.0-1.4.0.ppatch
+package main
+
+import (
+	"archive/tar"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bundleFormat is the version of the bundle layout written by this tool.
+const bundleFormat = 1
+
+// Manifest describes a bundle. It is stored as manifest.json, the first
+// entry of the tar archive, followed by its ed25519 signature in
+// manifest.sig and the payloads under files/.
+type Manifest struct {
+	Format      int       `json:"format"`
+	Product     string    `json:"product,omitempty"`
+	Version     string    `json:"version"`
+	BaseVersion string    `json:"baseVersion,omitempty"`
+	Delta       bool      `json:"delta"`
+	Created     time.Time `json:"created"`
+	// Files lists every file of the release in install order. Files not
+	// included in a delta bundle must already be installed.
+	Files []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	SHA256   string `json:"sha256"`
+	Included bool   `json:"included"`
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+	case "create":
+		create(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ppbundle keygen -out <key file>")
+	fmt.Fprintln(os.Stderr, "       ppbundle create -files <dir> -key <key file> -version <version> -out <bundle> [-from <dir>] [-product <id>] [-os <os> -arch <arch>]")
+	os.Exit(2)
+}
+
+func keygen(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "bundle.key", "File to write the private signing key to")
+	fs.Parse(args)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := os.Stat(*out); err == nil {
+		log.Fatalf("%s already exists", *out)
+	}
+	seed := base64.StdEncoding.EncodeToString(private.Seed())
+	if err := os.WriteFile(*out, []byte(seed+"\n"), 0600); err != nil {
+		log.Fatalf("Failed to write key: %v", err)
+	}
+	fmt.Printf("Private key written to %s. Keep it secret.\n", *out)
+	fmt.Printf("Public key for the patcher's \"bundlePublicKey\":\n%s\n", base64.StdEncoding.EncodeToString(public))
+}
+
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a ppbundle key", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func create(args []string) {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	filesDir := fs.String("files", "./files", "Directory of the release to package")
+	fromDir := fs.String("from", "", "Directory of the previous release; only changed files are included")
+	keyFile := fs.String("key", "bundle.key", "Private signing key from ppbundle keygen")
+	version := fs.String("version", "", "Version of the release")
+	baseVersion := fs.String("from-version", "", "Version of the previous release (delta bundles)")
+	product := fs.String("product", "", "Product ID the bundle is for (optional)")
+	goos := fs.String("os", "", "Package files for this OS from _platforms/ (optional)")
+	goarch := fs.String("arch", "", "Package files for this architecture from _platforms/ (optional)")
+	out := fs.String("out", "", "Bundle file to write")
+	fs.Parse(args)
+
+	if *version == "" || *out == "" {
+		usage()
+	}
+
+	key, err := loadKey(*keyFile)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	files, err := scanRelease(*filesDir, *goos, *goarch)
+	if err != nil {
+		log.Fatalf("Failed to scan %s: %v", *filesDir, err)
+	}
+
+	manifest := Manifest{
+		Format:      bundleFormat,
+		Product:     *product,
+		Version:     *version,
+		BaseVersion: *baseVersion,
+		Delta:       *fromDir != "",
+		Created:     time.Now().UTC(),
+	}
+
+	var base map[string]string
+	if manifest.Delta {
+		baseFiles, err := scanRelease(*fromDir, *goos, *goarch)
+		if err != nil {
+			log.Fatalf("Failed to scan %s: %v", *fromDir, err)
+		}
+		base = map[string]string{}
+		for _, file := range baseFiles {
+			base[file.Path] = file.SHA256
+		}
+	}
+
+	var payloadSize int64
+	for _, file := range files {
+		file.Included = !manifest.Delta || base[file.Path] != file.SHA256
+		if file.Included {
+			payloadSize += file.Size
+		}
+		manifest.Files = append(manifest.Files, file.ManifestFile)
+	}
+
+	if err := writeBundle(*out, key, manifest, files); err != nil {
+		os.Remove(*out)
+		log.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	included := 0
+	for _, file := range manifest.Files {
+		if file.Included {
+			included++
+		}
+	}
+	fmt.Printf("Wrote %s: %d of %d files, %d bytes of payload\n", *out, included, len(manifest.Files), payloadSize)
+}
+
+// releaseFile is a file of a release and where it lives on disk.
+type releaseFile struct {
+	ManifestFile
+	source string
+}
+
+// scanRelease lists and hashes the files of a release directory. With goos
+// set, files under _platforms/<os>[-<arch>]/ are installed without that
+// prefix and files for other platforms are skipped.
+func scanRelease(dir, goos, goarch string) ([]releaseFile, error) {
+	var files []releaseFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rest, ok := strings.CutPrefix(rel, "_platforms/"); ok && goos != "" {
+			platform, installPath, ok := strings.Cut(rest, "/")
+			if !ok {
+				return nil
+			}
+			fileOS, fileArch, _ := strings.Cut(platform, "-")
+			if fileOS != goos || (fileArch != "" && goarch != "" && fileArch != goarch) {
+				return nil
+			}
+			rel = installPath
+		}
+
+		md5Hash, shaHash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, releaseFile{
+			ManifestFile: ManifestFile{Path: rel, Size: info.Size(), Hash: md5Hash, SHA256: shaHash},
+			source:       path,
+		})
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	md5Hash := md5.New()
+	shaHash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, shaHash), f); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(shaHash.Sum(nil)), nil
+}
+
+func writeBundle(path string, key ed25519.PrivateKey, manifest Manifest, files []releaseFile) error {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	signature := ed25519.Sign(key, manifestJSON)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+	writeEntry := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := writeEntry("manifest.json", manifestJSON); err != nil {
+		return err
+	}
+	if err := writeEntry("manifest.sig", signature); err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		if !manifest.Files[i].Included {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "files/" + file.Path, Mode: 0644, Size: file.Size, ModTime: manifest.Created}); err != nil {
+			return err
+		}
+		in, err := os.Open(file.source)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, in)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
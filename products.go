@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"ppatcher/updater"
+)
+
+// defaultProductID identifies the single product of a config without a
+// products list.
+const defaultProductID = "default"
+
+// errUpdateInProgress is returned when an update of the same product is
+// already running.
+var errUpdateInProgress = newLocalError("error.updateInProgress")
+
+// product holds the install and update state of one managed product.
+type product struct {
+	app    *App
+	config ProductConfig
+	// mu guards the fields up to report, which the frontend, background
+	// checks and backend events read while a check or update changes them.
+	mu sync.RWMutex
+	// root is the directory the product's files are installed to. Every
+	// manifest path is resolved against it.
+	root   string
+	meta   MetaData
+	status string
+	// remoteVersion is the last version reported by the backend, with the
+	// oldest version it still allows to launch and whether remoteVersion
+	// must be installed to launch.
+	remoteVersion  string
+	minimumVersion string
+	mandatory      bool
+	// outdated is set when the last check found files to download.
+	outdated bool
+	news     NewsFeed
+	// pinned is the version the install is pinned to with InstallVersion,
+	// or "" to follow the latest release.
+	pinned string
+	// token is the license token sent to the backend, if any.
+	token      string
+	activation ActivationInfo
+	// maintenance is the last maintenance notice pushed by the backend.
+	maintenance Maintenance
+	// report is the result of the last update run, for diagnostics.
+	report *UpdateReport
+	// notifiedHash is the last remote hash announced by a background check.
+	// Only background checks use it, under updating.
+	notifiedHash string
+	// updating is held for the whole duration of a check or update.
+	updating sync.Mutex
+	// sink also receives the progress of updates, as in headless mode.
+	sink updater.EventSink
+}
+
+// ProductInfo is a product as listed to the frontend.
+type ProductInfo struct {
+	ID             string          `json:"id"`
+	DisplayName    string          `json:"displayName"`
+	Description    string          `json:"description"`
+	Version        string          `json:"version"`
+	Logo           string          `json:"logo"`
+	InstallDir     string          `json:"installDir"`
+	Status         string          `json:"status"`
+	LaunchProfiles []LaunchProfile `json:"launchProfiles"`
+	Launchable     bool            `json:"launchable"`
+	Selected       bool            `json:"selected"`
+}
+
+// productConfigs returns the products of BuildConfig, with missing values
+// taken from the top-level fields.
+func productConfigs() []ProductConfig {
+	if len(BuildConfig.Products) == 0 {
+		return []ProductConfig{{
+			ID:              defaultProductID,
+			DisplayName:     BuildConfig.DisplayName,
+			Description:     BuildConfig.Description,
+			Version:         BuildConfig.Version,
+			Backend:         BuildConfig.Backend,
+			FallbackURLs:    BuildConfig.FallbackURLs,
+			InstallDir:      BuildConfig.InstallDir,
+			Executable:      BuildConfig.Executable,
+			LaunchProfiles:  BuildConfig.LaunchProfiles,
+			Logo:            BuildConfig.Logo,
+			BundlePublicKey: BuildConfig.BundlePublicKey,
+		}}
+	}
+
+	configs := make([]ProductConfig, len(BuildConfig.Products))
+	for i, config := range BuildConfig.Products {
+		if config.ID == "" {
+			config.ID = "product-" + strconv.Itoa(i+1)
+		}
+		if config.DisplayName == "" {
+			config.DisplayName = config.ID
+		}
+		if config.Backend == "" {
+			config.Backend = BuildConfig.Backend
+		}
+		if config.BundlePublicKey == "" {
+			config.BundlePublicKey = BuildConfig.BundlePublicKey
+		}
+		configs[i] = config
+	}
+	return configs
+}
+
+// current returns the selected product.
+func (a *App) current() *product {
+	a.selectedMu.RLock()
+	selected := a.selected
+	a.selectedMu.RUnlock()
+	if p := a.product(selected); p != nil {
+		return p
+	}
+	return a.products[0]
+}
+
+// product returns the product with the given ID, or nil.
+func (a *App) product(id string) *product {
+	for _, p := range a.products {
+		if p.config.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// emit sends a product event to the frontend as "product:<event>" with the
+// product ID attached. Events of the selected product are also sent under
+// their plain name, which is what the single-product UI listens to.
+func (p *product) emit(event string, data interface{}) {
+	if p.app.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(p.app.ctx, "product:"+event, map[string]interface{}{
+		"product": p.config.ID,
+		"data":    data,
+	})
+	if p.app.current() == p {
+		runtime.EventsEmit(p.app.ctx, event, data)
+	}
+}
+
+// version returns the product's latest known version.
+func (p *product) version() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.remoteVersion != "" {
+		return p.remoteVersion
+	}
+	return p.config.Version
+}
+
+func (p *product) setVersion(version string) {
+	p.mu.Lock()
+	p.remoteVersion = version
+	p.mu.Unlock()
+}
+
+// versionPolicy returns the oldest version the backend allows to launch
+// and whether its latest version must be installed.
+func (p *product) versionPolicy() (minimum string, mandatory bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.minimumVersion, p.mandatory
+}
+
+func (p *product) setVersionPolicy(minimum string, mandatory bool) {
+	p.mu.Lock()
+	p.minimumVersion, p.mandatory = minimum, mandatory
+	p.mu.Unlock()
+}
+
+// rootDir returns the product's install directory, or "" while the player
+// still has to pick one.
+func (p *product) rootDir() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.root
+}
+
+func (p *product) setRoot(dir string) {
+	p.mu.Lock()
+	p.root = dir
+	p.mu.Unlock()
+}
+
+// currentStatus returns the last status set with setStatus.
+func (p *product) currentStatus() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// remoteMeta returns the remote meta found by the last check.
+func (p *product) remoteMeta() MetaData {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.meta
+}
+
+func (p *product) setMeta(meta MetaData) {
+	p.mu.Lock()
+	p.meta = meta
+	p.mu.Unlock()
+}
+
+// isOutdated reports whether the last check found files to download.
+func (p *product) isOutdated() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.outdated
+}
+
+func (p *product) setOutdated(outdated bool) {
+	p.mu.Lock()
+	p.outdated = outdated
+	p.mu.Unlock()
+}
+
+// pinnedVersion returns the version the install is pinned to, or "".
+func (p *product) pinnedVersion() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pinned
+}
+
+func (p *product) setPinned(version string) {
+	p.mu.Lock()
+	p.pinned = version
+	p.mu.Unlock()
+}
+
+// backendURLs returns the primary backend followed by any fallback URLs.
+func (p *product) backendURLs() []string {
+	urls := []string{p.config.Backend}
+	urls = append(urls, p.config.FallbackURLs...)
+	return urls
+}
+
+// client returns an updater for the product's install directory, using the
+// shared HTTP clients and the network settings of the build config.
+func (p *product) client() *updater.Client {
+	last := []string{p.config.Executable}
+	for _, profile := range p.config.LaunchProfiles {
+		last = append(last, profile.Executable)
+	}
+	network := BuildConfig.Network
+	p.mu.RLock()
+	root, token := p.root, p.token
+	p.mu.RUnlock()
+	return &updater.Client{
+		Backends:       p.backendURLs(),
+		Root:           root,
+		Token:          token,
+		RolloutID:      rolloutID(),
+		Release:        p.pinnedVersion(),
+		HTTPClient:     apiClient,
+		DownloadClient: downloadClient,
+		IdleTimeout:    downloadIdleTimeout,
+		MinDownloads:   network.MinDownloads,
+		MaxDownloads:   network.MaxDownloads,
+		BatchThreshold: network.BatchThreshold,
+		// The executables only start once everything else is installed.
+		Last:   last,
+		Logger: logger.With("product", p.config.ID),
+	}
+}
+
+// get requests path from the product's backend, moving on to the next
+// fallback URL when a backend is unreachable or failing.
+func (p *product) get(path string) (*http.Response, error) {
+	return p.request(http.MethodGet, path, nil, 0)
+}
+
+// request sends a request to the first of the product's backends that
+// answers; see updater.Client.Request.
+func (p *product) request(method, path string, body func() io.Reader, idleTimeout time.Duration) (*http.Response, error) {
+	return p.client().Request(context.Background(), method, path, body, idleTimeout)
+}
+
+// launchProfile resolves a launch profile ID. An empty ID means the
+// product's executable, or its first profile if it has none.
+func (p *product) launchProfile(id string) (LaunchProfile, error) {
+	if id == "" {
+		if p.config.Executable != "" || len(p.config.LaunchProfiles) == 0 {
+			return LaunchProfile{Executable: p.config.Executable}, nil
+		}
+		id = p.config.LaunchProfiles[0].ID
+	}
+
+	for _, profile := range p.config.LaunchProfiles {
+		if profile.ID == id {
+			if profile.Executable == "" {
+				profile.Executable = p.config.Executable
+			}
+			return profile, nil
+		}
+	}
+	return LaunchProfile{}, fmt.Errorf("unknown launch profile %q", id)
+}
+
+func (p *product) info() ProductInfo {
+	return ProductInfo{
+		ID:             p.config.ID,
+		DisplayName:    p.config.DisplayName,
+		Description:    p.config.Description,
+		Version:        p.version(),
+		Logo:           p.config.Logo,
+		InstallDir:     p.rootDir(),
+		Status:         p.currentStatus(),
+		LaunchProfiles: p.config.LaunchProfiles,
+		Launchable:     p.config.Executable != "" || len(p.config.LaunchProfiles) > 0,
+		Selected:       p == p.app.current(),
+	}
+}
+
+// Products lists the products managed by the patcher.
+func (a *App) Products() []ProductInfo {
+	infos := make([]ProductInfo, 0, len(a.products))
+	for _, p := range a.products {
+		infos = append(infos, p.info())
+	}
+	return infos
+}
+
+// SelectProduct makes the product with the given ID the one the
+// single-product methods (ManualUpdate, StartExecutable, InstallDir, ...)
+// and events refer to.
+func (a *App) SelectProduct(id string) error {
+	if a.product(id) == nil {
+		return fmt.Errorf("unknown product %q", id)
+	}
+	a.selectedMu.Lock()
+	a.selected = id
+	a.selectedMu.Unlock()
+	return nil
+}
+
+// UpdateProduct checks the product with the given ID for updates and
+// installs them.
+func (a *App) UpdateProduct(id string) error {
+	p := a.product(id)
+	if p == nil {
+		return fmt.Errorf("unknown product %q", id)
+	}
+	return p.manualUpdate()
+}
+
+// LaunchProduct starts the product with the given ID using a launch
+// profile. An empty profile ID starts the product's default executable.
+func (a *App) LaunchProduct(id string, profileID string) error {
+	p := a.product(id)
+	if p == nil {
+		return fmt.Errorf("unknown product %q", id)
+	}
+	return p.launch(profileID)
+}
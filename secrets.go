@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Secrets such as license tokens are kept in the OS credential store where
+// one is available: DPAPI on Windows, the login keychain on macOS and the
+// Secret Service (through secret-tool) on Linux. loadSecret, saveSecret and
+// deleteSecret are implemented per platform in secrets_*.go.
+
+// secretService is the service name secrets are stored under.
+func secretService() string {
+	return appDirName()
+}
+
+// secretFilePath returns the per-user file a secret is stored in when the
+// platform has no credential store.
+func secretFilePath(account string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appDirName(), "secrets", safeDirName(account)), nil
+}
+
+// readSecretFile returns the contents of a secret file, or nil if it
+// doesn't exist.
+func readSecretFile(account string) ([]byte, error) {
+	path, err := secretFilePath(account)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeSecretFile stores data in a file only the current user can read.
+func writeSecretFile(account string, data []byte) error {
+	path, err := secretFilePath(account)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeSecretFile(account string) error {
+	path, err := secretFilePath(account)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
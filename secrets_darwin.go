@@ -0,0 +1,42 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of the security tool for a missing
+// keychain item.
+const errSecItemNotFound = 44
+
+// loadSecret returns a secret from the login keychain, or "" if there is
+// none.
+func loadSecret(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", secretService(), "-a", account, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// saveSecret stores a secret in the login keychain, replacing an existing
+// one.
+func saveSecret(account, value string) error {
+	return exec.Command("security", "add-generic-password", "-U", "-s", secretService(), "-a", account, "-w", value).Run()
+}
+
+func deleteSecret(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", secretService(), "-a", account).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// secretToolAvailable reports whether the Secret Service can be reached
+// through secret-tool (libsecret).
+func secretToolAvailable() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+// loadSecret returns a secret from the Secret Service, or from the fallback
+// file if there is no Secret Service. It returns "" if there is none.
+func loadSecret(account string) (string, error) {
+	if secretToolAvailable() {
+		out, err := exec.Command("secret-tool", "lookup", "service", secretService(), "account", account).Output()
+		if err == nil && len(out) > 0 {
+			return strings.TrimRight(string(out), "\n"), nil
+		}
+	}
+	data, err := readSecretFile(account)
+	return string(data), err
+}
+
+// saveSecret stores a secret in the Secret Service, falling back to a file
+// only the current user can read when no keyring is running.
+func saveSecret(account, value string) error {
+	if secretToolAvailable() {
+		cmd := exec.Command("secret-tool", "store", "--label", secretService()+" "+account, "service", secretService(), "account", account)
+		cmd.Stdin = bytes.NewBufferString(value)
+		if err := cmd.Run(); err == nil {
+			return removeSecretFile(account)
+		}
+		logger.Warn("secret service unavailable, storing secret in a file", "account", account)
+	}
+	return writeSecretFile(account, []byte(value))
+}
+
+func deleteSecret(account string) error {
+	if secretToolAvailable() {
+		exec.Command("secret-tool", "clear", "service", secretService(), "account", account).Run()
+	}
+	return removeSecretFile(account)
+}
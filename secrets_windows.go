@@ -0,0 +1,80 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+// cryptprotectUIForbidden fails instead of prompting the user.
+const cryptprotectUIForbidden = 0x1
+
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{cbData: uint32(len(data)), pbData: &data[0]}
+}
+
+// bytes copies the blob's data and frees the buffer DPAPI allocated.
+func (b *dataBlob) bytes() []byte {
+	data := make([]byte, b.cbData)
+	copy(data, unsafe.Slice(b.pbData, b.cbData))
+	procLocalFree.Call(uintptr(unsafe.Pointer(b.pbData)))
+	return data
+}
+
+// dpapi encrypts or decrypts data for the current Windows user.
+func dpapi(proc *syscall.LazyProc, data []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := proc.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))),
+		0, 0, 0, 0,
+		cryptprotectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if r == 0 {
+		return nil, err
+	}
+	return out.bytes(), nil
+}
+
+// loadSecret returns a stored secret, or "" if there is none.
+func loadSecret(account string) (string, error) {
+	data, err := readSecretFile(account)
+	if err != nil || data == nil {
+		return "", err
+	}
+	plain, err := dpapi(procCryptUnprotectData, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// saveSecret stores a secret encrypted with DPAPI for the current user.
+func saveSecret(account, value string) error {
+	data, err := dpapi(procCryptProtectData, []byte(value))
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(account, data)
+}
+
+func deleteSecret(account string) error {
+	return removeSecretFile(account)
+}
@@ -0,0 +1,118 @@
+// Package semver parses and compares the semantic versions of releases, for
+// the patcher and the server alike.
+package semver
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. A leading "v" and missing minor
+// or patch numbers are accepted, so "v2.1" is 2.1.0. Build metadata after
+// "+" is ignored, as semver requires.
+type Version struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+// Parse parses a semantic version such as "1.4.2", "v2.0" or
+// "3.0.0-beta.2". It reports false for anything else.
+func Parse(s string) (Version, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	s, _, _ = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return Version{}, false
+	}
+	var numbers [3]uint64
+	for i, part := range parts {
+		if part == "" || (len(part) > 1 && part[0] == '0') {
+			return Version{}, false
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return Version{}, false
+		}
+		numbers[i] = n
+	}
+
+	v := Version{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+		for _, identifier := range v.pre {
+			if identifier == "" {
+				return Version{}, false
+			}
+		}
+	}
+	return v, true
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// Pre-releases come before their release: 2.0.0-rc.1 < 2.0.0.
+func (v Version) Compare(o Version) int {
+	for _, pair := range [][2]uint64{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePrerelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.pre) < len(o.pre):
+		return -1
+	case len(v.pre) > len(o.pre):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares two pre-release identifiers: numbers by value
+// and below any text, text in ASCII order.
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if na == nb {
+			return 0
+		}
+		if na < nb {
+			return -1
+		}
+		return 1
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Compare compares two version strings as semantic versions. ok is
+// false if either of them isn't one.
+func Compare(a, b string) (c int, ok bool) {
+	va, okA := Parse(a)
+	vb, okB := Parse(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return va.Compare(vb), true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// maxBatchPaths limits the number of files one /batch request may ask for.
+const maxBatchPaths = 1000
+
+// batchHandler streams several files of the current release in one
+// response, so that patchers don't pay a request per small file.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	cacheMutex.RLock()
+	release := servedRelease
+	cacheMutex.RUnlock()
+	if release != nil {
+		serveRelease(w, r, release, "batch")
+		return
+	}
+	serveBatch(w, r, func(name string) string {
+		return filepath.Join(filesDir, filepath.FromSlash(name))
+	})
+}
+
+// serveBatch answers POST {"paths": [...]} with a tar archive holding the
+// requested files, in the requested order. paths are the same as under
+// /files/; locate returns where a cleaned path is stored, or "". Entries
+// that don't exist are left out, so the client downloads those on their
+// own; every entry is verified by the client against its manifest while
+// extracting.
+func serveBatch(w http.ResponseWriter, r *http.Request, locate func(name string) string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var request struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil || len(request.Paths) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+	if len(request.Paths) > maxBatchPaths {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": "too many paths"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	tw := tar.NewWriter(w)
+	for _, name := range request.Paths {
+		if err := writeBatchEntry(tw, locate, name); err != nil {
+			// The status is already sent; cutting the stream short makes
+			// the client fetch the remaining files on their own.
+			log.Printf("Batch download of %s failed: %v", name, err)
+			return
+		}
+	}
+	tw.Close()
+}
+
+// writeBatchEntry adds the file stored for name to tw. A missing file or
+// directory is skipped without error.
+func writeBatchEntry(tw *tar.Writer, locate func(name string) string, name string) error {
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" {
+		return nil
+	}
+	stored := locate(clean)
+	if stored == "" {
+		return nil
+	}
+	file, err := os.Open(stored)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	buf := bufferPool.Get().([]byte)
+	defer bufferPool.Put(buf)
+	_, err = io.CopyBuffer(tw, io.LimitReader(file, info.Size()), buf)
+	return err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The blob store holds the payloads of every archived release once, by
+// content hash, under blobsDir/<first two hex digits>/<hash>. The hash is the
+// md5 the manifests list, so a manifest entry names its blob and identical
+// files of different releases, channels and platforms share it. Blobs are
+// never modified; they are removed once no release lists them.
+
+// currentBlobs maps the hashes of filesMetaList to the disk paths of their
+// files, guarded by cacheMutex.
+var currentBlobs map[string]string
+
+// blobPaths returns the disk path of a file per hash of files.
+func blobPaths(files []MetaForFile) map[string]string {
+	paths := make(map[string]string, len(files))
+	for _, file := range files {
+		if _, ok := paths[file.Hash]; !ok {
+			paths[file.Hash] = diskPath(file)
+		}
+	}
+	return paths
+}
+
+// blobPath returns where the blob with the given hash is stored.
+func blobPath(hash string) string {
+	return filepath.Join(blobsDir, hash[:2], hash)
+}
+
+// validBlobHash reports whether s is a hash as the manifests list it: 32
+// lower-case hex digits.
+func validBlobHash(s string) bool {
+	if len(s) != 32 || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// storeBlob copies src into the blob store unless the blob already exists.
+// The copy is checked against hash, like archiveFile.
+func storeBlob(src, hash string) error {
+	dst := blobPath(hash)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), hash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := archiveFile(src, tmp.Name(), hash); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// migrateRelease moves the payloads of an archive from before the blob
+// store, kept below "files" in its directory, into the store. The archive
+// was verified when it was made, so its files are moved rather than copied.
+func migrateRelease(release *archivedRelease) error {
+	dir := filepath.Join(release.dir, "files")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	for _, file := range release.Files {
+		if !validBlobHash(file.Hash) {
+			continue
+		}
+		dst := blobPath(file.Hash)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(dir, filepath.FromSlash(diskPath(file))), dst); err != nil {
+			return err
+		}
+	}
+	log.Printf("Moved the files of release %s into the blob store", release.ID)
+	return os.RemoveAll(dir)
+}
+
+// collectBlobs removes the blobs no archived release lists anymore. Callers
+// must hold publishMutex, so that no release is being archived, and
+// releasesMutex.
+func collectBlobs() {
+	listed := map[string]bool{}
+	for _, release := range releases {
+		for _, file := range release.Files {
+			listed[file.Hash] = true
+		}
+	}
+
+	var removed int
+	var freed int64
+	filepath.WalkDir(blobsDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || listed[entry.Name()] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove blob %s: %v", entry.Name(), err)
+			return nil
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	if removed > 0 {
+		log.Printf("Removed %d unused blobs (%d bytes)", removed, freed)
+	}
+}
+
+// blobsHandler serves GET /blobs/{hash}. A blob never changes, so responses
+// may be cached forever. Files changed in filesDir since the last archive
+// aren't in the store yet; they are served from there, uncached.
+func blobsHandler(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/blobs/")
+	if !validBlobHash(hash) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "blob not found"})
+		return
+	}
+
+	stored := true
+	file, err := os.Open(blobPath(hash))
+	if os.IsNotExist(err) {
+		stored = false
+		file, err = openCurrentBlob(hash)
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "blob not found"})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if stored {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("ETag", `"`+hash+`"`)
+	} else {
+		// The file may change again before it is archived.
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// filesHandler serves /files/ from filesDir, or from the blob store while
+// the current release is served from there.
+func filesHandler(w http.ResponseWriter, r *http.Request) {
+	cacheMutex.RLock()
+	release := servedRelease
+	cacheMutex.RUnlock()
+	if release != nil {
+		serveRelease(w, r, release, strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+		return
+	}
+	http.StripPrefix("/files/", http.FileServer(http.Dir(filesDir))).ServeHTTP(w, r)
+}
+
+// openCurrentBlob opens the file of filesDir the current manifest lists
+// with hash.
+func openCurrentBlob(hash string) (*os.File, error) {
+	cacheMutex.RLock()
+	path, ok := currentBlobs[hash]
+	cacheMutex.RUnlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(filesDir, filepath.FromSlash(path)))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventBufferSize is the number of past events kept for clients that
+	// reconnect with Last-Event-ID.
+	eventBufferSize = 256
+	// eventHeartbeat is the interval of keep-alive comments on /events.
+	eventHeartbeat = 25 * time.Second
+	// eventRetry is the reconnect delay suggested to clients, in ms.
+	eventRetry = 5000
+)
+
+// serverEvent is a message broadcast on /events.
+type serverEvent struct {
+	ID   int64
+	Type string
+	Data []byte
+}
+
+// eventBroker fans events out to /events subscribers and keeps a ring
+// buffer of recent events for Last-Event-ID resume.
+type eventBroker struct {
+	mu     sync.Mutex
+	nextID int64
+	buffer []serverEvent
+	subs   map[chan serverEvent]struct{}
+}
+
+// events is the server's broker. IDs start at the startup time so that IDs
+// from before a restart are never mistaken for current ones.
+var events = &eventBroker{
+	nextID: time.Now().UnixMilli(),
+	subs:   map[chan serverEvent]struct{}{},
+}
+
+// publish broadcasts an event to every subscriber. Subscribers that can't
+// keep up miss the event and resync when they reconnect.
+func (b *eventBroker) publish(eventType string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	event := serverEvent{ID: b.nextID, Type: eventType, Data: payload}
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > eventBufferSize {
+		b.buffer = b.buffer[len(b.buffer)-eventBufferSize:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			// Drop slow subscribers; they reconnect and resume.
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the buffered events after
+// lastID. missed is true if events after lastID are no longer buffered.
+func (b *eventBroker) subscribe(lastID int64) (ch chan serverEvent, replay []serverEvent, missed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch = make(chan serverEvent, 16)
+	b.subs[ch] = struct{}{}
+	if lastID <= 0 {
+		return ch, nil, false
+	}
+	if lastID > b.nextID || (len(b.buffer) > 0 && lastID < b.buffer[0].ID-1) || (len(b.buffer) == 0 && lastID < b.nextID) {
+		return ch, nil, true
+	}
+	for _, event := range b.buffer {
+		if event.ID > lastID {
+			replay = append(replay, event)
+		}
+	}
+	return ch, replay, false
+}
+
+func (b *eventBroker) unsubscribe(ch chan serverEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// maintenanceState is the persisted form of maintenance.json.
+type maintenanceState struct {
+	Active  bool       `json:"active"`
+	Message string     `json:"message,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+var (
+	maintenance      maintenanceState
+	maintenanceMutex sync.RWMutex
+)
+
+func loadMaintenance() error {
+	data, err := os.ReadFile(maintenanceFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	maintenanceMutex.Lock()
+	defer maintenanceMutex.Unlock()
+	return json.Unmarshal(data, &maintenance)
+}
+
+func writeEvent(w http.ResponseWriter, event serverEvent) {
+	if event.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+}
+
+// eventsHandler streams server events as Server-Sent Events: "manifest"
+// when the files change, "version" when the release version changes and
+// "maintenance" notices. A "reset" event tells a resuming client that it
+// missed events and should check for updates.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	lastID, _ := strconv.ParseInt(strings.TrimSpace(r.Header.Get("Last-Event-ID")), 10, 64)
+	if lastID == 0 {
+		lastID, _ = strconv.ParseInt(r.URL.Query().Get("lastEventId"), 10, 64)
+	}
+
+	ch, replay, missed := events.subscribe(lastID)
+	defer events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+
+	// The current maintenance state always comes first, so clients that
+	// reconnect never show a stale notice.
+	maintenanceMutex.RLock()
+	state, _ := json.Marshal(maintenance)
+	maintenanceMutex.RUnlock()
+	writeEvent(w, serverEvent{Type: "maintenance", Data: state})
+
+	if missed {
+		writeEvent(w, serverEvent{Type: "reset", Data: []byte("{}")})
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// adminMaintenanceHandler returns (GET) or sets (POST) the maintenance
+// notice and broadcasts changes to connected clients.
+func adminMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		maintenanceMutex.RLock()
+		defer maintenanceMutex.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maintenance)
+
+	case http.MethodPost:
+		var state maintenanceState
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&state); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid maintenance state"})
+			return
+		}
+		state.Message = strings.TrimSpace(state.Message)
+
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err == nil {
+			err = os.WriteFile(maintenanceFile, data, 0644)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to save maintenance state"})
+			return
+		}
+		maintenanceMutex.Lock()
+		maintenance = state
+		maintenanceMutex.Unlock()
+
+		events.publish("maintenance", state)
+		log.Printf("[admin] maintenance active=%v", state.Active)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "maintenance": state})
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// baseGroup is the group of every file that no group definition claims. It
+// is always installed.
+const baseGroup = "base"
+
+// groupDef describes an optional component such as HD textures, a voice
+// pack or a DLC. Files are assigned to the first group with a matching
+// pattern (same syntax as platforms.json).
+type groupDef struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Patterns    []string `json:"patterns"`
+	Required    bool     `json:"required"`
+	Default     bool     `json:"default"`
+}
+
+type groupDefs struct {
+	Groups []groupDef `json:"groups"`
+}
+
+// GroupMeta is the per-group summary served in /filesmeta.
+type GroupMeta struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Default     bool   `json:"default"`
+	Size        int64  `json:"size"`
+	Files       int    `json:"files"`
+}
+
+// loadGroupDefs reads the optional groups.json sidecar. A missing file means
+// the release has no optional components.
+func loadGroupDefs() ([]groupDef, error) {
+	data, err := os.ReadFile(groupsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var defs groupDefs
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, err
+	}
+	return defs.Groups, nil
+}
+
+// assignGroups sets the Group field of every file. Files outside all groups
+// stay in the implicit base group, which is left empty to keep manifests of
+// group-less releases unchanged.
+func assignGroups(files []MetaForFile, defs []groupDef) {
+	for i := range files {
+		for _, def := range defs {
+			if def.ID == baseGroup {
+				continue
+			}
+			matched := false
+			for _, pattern := range def.Patterns {
+				if matchPattern(pattern, files[i].Path) {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				files[i].Group = def.ID
+				break
+			}
+		}
+	}
+}
+
+// summarizeGroups returns the group list for a manifest, with sizes and file
+// counts computed over files. It returns nil if no groups are defined.
+func summarizeGroups(defs []groupDef, files []MetaForFile) []GroupMeta {
+	if len(defs) == 0 {
+		return nil
+	}
+
+	groups := []GroupMeta{{ID: baseGroup, Name: "Base", Required: true, Default: true}}
+	index := map[string]int{baseGroup: 0}
+	for _, def := range defs {
+		if def.ID == baseGroup {
+			// Allow renaming the base group, but it stays required.
+			if def.Name != "" {
+				groups[0].Name = def.Name
+			}
+			groups[0].Description = def.Description
+			continue
+		}
+		index[def.ID] = len(groups)
+		groups = append(groups, GroupMeta{
+			ID:          def.ID,
+			Name:        def.Name,
+			Description: def.Description,
+			Required:    def.Required,
+			Default:     def.Default || def.Required,
+		})
+	}
+
+	for _, fileMeta := range files {
+		id := fileMeta.Group
+		if id == "" {
+			id = baseGroup
+		}
+		if i, ok := index[id]; ok {
+			groups[i].Size += fileMeta.Size
+			groups[i].Files++
+		}
+	}
+
+	return groups
+}
+
+// groupIDs returns the IDs of groups, for the /meta summary.
+func groupIDs(groups []GroupMeta) []string {
+	var ids []string
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	return ids
+}
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ppatcher/semver"
+)
+
+// archivedRelease is the release.json of a directory under versionsDir: the
+// manifest of a published version. Its payloads are kept in the blob store.
+// Releases are never modified once archived.
+type archivedRelease struct {
+	// ID identifies the release. Archives from before releases had IDs use
+	// their directory name.
+	ID        string        `json:"id"`
+	Version   string        `json:"version"`
+	Notes     string        `json:"notes,omitempty"`
+	Uploader  string        `json:"uploader,omitempty"`
+	Hash      string        `json:"hash"`
+	TotalSize int64         `json:"totalSize"`
+	Created   time.Time     `json:"created"`
+	Files     []MetaForFile `json:"files"`
+	Groups    []groupDef    `json:"groups,omitempty"`
+
+	dir string
+	// blobs maps the on-disk paths of the files to their hashes.
+	blobs map[string]string
+}
+
+// index fills in the blobs of a loaded or new release.
+func (r *archivedRelease) index() {
+	r.blobs = make(map[string]string, len(r.Files))
+	for _, file := range r.Files {
+		r.blobs[diskPath(file)] = file.Hash
+	}
+}
+
+// ReleaseInfo is an archived release as listed by /versions.
+type ReleaseInfo struct {
+	Version   string    `json:"version"`
+	Hash      string    `json:"hash"`
+	TotalSize int64     `json:"totalSize"`
+	Created   time.Time `json:"created"`
+	Current   bool      `json:"current"`
+}
+
+// AdminReleaseInfo is a release as listed by /admin/releases.
+type AdminReleaseInfo struct {
+	ID        string    `json:"id"`
+	Version   string    `json:"version"`
+	Notes     string    `json:"notes,omitempty"`
+	Uploader  string    `json:"uploader,omitempty"`
+	Hash      string    `json:"hash"`
+	TotalSize int64     `json:"totalSize"`
+	Files     int       `json:"files"`
+	Created   time.Time `json:"created"`
+	Current   bool      `json:"current"`
+	// Rollout is the percentage of patchers the release is rolled out to.
+	Rollout int `json:"rollout,omitempty"`
+}
+
+// currentState is currentFile: the current release and the ones that were
+// current before it, oldest first, for rollbacks.
+type currentState struct {
+	Current string   `json:"current"`
+	History []string `json:"history,omitempty"`
+	// Stale is the manifest hash filesDir had when another release was
+	// promoted. While it is set, the current release is served from the
+	// blob store instead of filesDir; see staleRelease.
+	Stale string `json:"stale,omitempty"`
+}
+
+var (
+	// releases holds the archived releases by ID.
+	releases      = map[string]*archivedRelease{}
+	current       currentState
+	releasesMutex sync.RWMutex
+)
+
+// publishMutex serializes everything that replaces filesDir.
+var publishMutex sync.Mutex
+
+// errReleaseNotFound is returned for unknown release IDs.
+var errReleaseNotFound = errors.New("release not found")
+
+// keepVersions is how many archived releases to keep, from KEEP_VERSIONS.
+// 0 keeps every release.
+var keepVersions int
+
+// loadReleases reads the release.json of every archived release.
+func loadReleases() error {
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	releasesMutex.Lock()
+	defer releasesMutex.Unlock()
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		dir := filepath.Join(versionsDir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "release.json"))
+		if err != nil {
+			log.Printf("Failed to read archived release %s: %v", entry.Name(), err)
+			continue
+		}
+		var release archivedRelease
+		if err := json.Unmarshal(data, &release); err != nil {
+			log.Printf("Failed to read archived release %s: %v", entry.Name(), err)
+			continue
+		}
+		if release.ID == "" {
+			release.ID = entry.Name()
+		}
+		release.dir = dir
+		if err := migrateRelease(&release); err != nil {
+			log.Printf("Failed to move release %s into the blob store: %v", release.ID, err)
+		}
+		release.index()
+		releases[release.ID] = &release
+	}
+
+	data, err := os.ReadFile(currentFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &current)
+}
+
+// saveCurrent writes the current release and its history to currentFile.
+// Callers must hold releasesMutex.
+func saveCurrent() error {
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := currentFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, currentFile)
+}
+
+// setCurrent records id as the current release. A rollback returns to the
+// last release of the history, which is taken off it; otherwise the release
+// that was current is added to it. Callers must hold releasesMutex.
+func setCurrent(id string, rollback bool) {
+	if rollback {
+		for len(current.History) > 0 {
+			last := current.History[len(current.History)-1]
+			current.History = current.History[:len(current.History)-1]
+			if last == id {
+				break
+			}
+		}
+	} else if current.Current != "" && current.Current != id {
+		current.History = append(current.History, current.Current)
+	}
+	current.Current = id
+	if err := saveCurrent(); err != nil {
+		log.Printf("Failed to save current release: %v", err)
+	}
+}
+
+// newReleaseID returns an ID that sorts by upload time. Should the random
+// suffix be unavailable, the microseconds of the time take its place.
+func newReleaseID() string {
+	now := time.Now().UTC()
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Failed to generate a random release ID: %v", err)
+		return fmt.Sprintf("%s-%06d", now.Format("20060102-150405"), now.Nanosecond()/1000)
+	}
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// archiveRelease stores the current manifest as a new release of the given
+// version and makes it the current one. Only payloads the blob store doesn't
+// have yet are copied. Callers must hold publishMutex.
+func archiveRelease(version, notes, uploader string) (*archivedRelease, error) {
+	cacheMutex.RLock()
+	files := filesMetaList
+	defs := groupDefsCache
+	hash := manifestHash
+	cacheMutex.RUnlock()
+	return storeRelease(filesDir, files, defs, hash, version, notes, uploader, true)
+}
+
+// stageRelease stores the files below dir as a new release of the given
+// version without making it current, so that it can be rolled out or
+// promoted later. Callers must hold publishMutex.
+func stageRelease(dir, version, notes, uploader string) (*archivedRelease, error) {
+	files, _, err := calculateFilesMeta(dir)
+	if err != nil {
+		return nil, err
+	}
+	defs, err := loadGroupDefs()
+	if err != nil {
+		return nil, err
+	}
+	assignGroups(files, defs)
+	sortManifest(files)
+	unscoped := unscopedFiles(files)
+	sortManifest(unscoped)
+	hash, err := calculateOverallHash(unscoped)
+	if err != nil {
+		return nil, err
+	}
+	return storeRelease(dir, files, defs, hash, version, notes, uploader, false)
+}
+
+// storeRelease archives files, stored below dir, as a new release and makes
+// it current if asked to. Callers must hold publishMutex.
+func storeRelease(dir string, files []MetaForFile, defs []groupDef, hash, version, notes, uploader string, makeCurrent bool) (*archivedRelease, error) {
+	if err := os.MkdirAll(versionsDir, 0755); err != nil {
+		return nil, err
+	}
+	id := newReleaseID()
+	releaseDir := filepath.Join(versionsDir, id)
+	tmp := releaseDir + ".tmp"
+	os.RemoveAll(tmp)
+
+	release := &archivedRelease{
+		ID:       id,
+		Version:  version,
+		Notes:    notes,
+		Uploader: uploader,
+		Hash:     hash,
+		Created:  time.Now().UTC(),
+		Files:    files,
+		Groups:   defs,
+		dir:      releaseDir,
+	}
+	for _, file := range files {
+		release.TotalSize += file.Size
+		if err := storeBlob(filepath.Join(dir, filepath.FromSlash(diskPath(file))), file.Hash); err != nil {
+			return nil, err
+		}
+	}
+	release.index()
+
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "release.json"), data, 0644); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	releasesMutex.Lock()
+	defer releasesMutex.Unlock()
+	if err := os.Rename(tmp, releaseDir); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	releases[id] = release
+	if makeCurrent {
+		setCurrent(id, false)
+	}
+	pruneReleases()
+	return release, nil
+}
+
+// archiveFile copies src to dst and checks that it still has the hash the
+// manifest recorded, so that a file changed since the manifest was built
+// isn't archived under the wrong version.
+func archiveFile(src, dst, hash string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	sum := md5.New()
+	buf := bufferPool.Get().([]byte)
+	_, err = io.CopyBuffer(io.MultiWriter(out, sum), in, buf)
+	bufferPool.Put(buf)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(sum.Sum(nil)) != hash {
+		return fmt.Errorf("%s changed while it was archived", src)
+	}
+	return nil
+}
+
+// pruneReleases removes the oldest archives beyond keepVersions, never the
+// current one, the one a rollback returns to or the one being rolled out,
+// and then the blobs only they listed. Callers must hold publishMutex and
+// releasesMutex.
+func pruneReleases() {
+	if keepVersions <= 0 || len(releases) <= keepVersions {
+		return
+	}
+	defer collectBlobs()
+	list := sortedReleases()
+	rolling := rolloutReleaseID()
+	previous := ""
+	for i := len(current.History) - 1; i >= 0 && previous == ""; i-- {
+		if _, ok := releases[current.History[i]]; ok {
+			previous = current.History[i]
+		}
+	}
+	for _, release := range list[keepVersions:] {
+		if release.ID == current.Current || release.ID == previous || release.ID == rolling {
+			continue
+		}
+		if err := os.RemoveAll(release.dir); err != nil {
+			log.Printf("Failed to remove archived release %s: %v", release.ID, err)
+			continue
+		}
+		delete(releases, release.ID)
+		log.Printf("Removed archived release %s (version %s)", release.ID, release.Version)
+	}
+}
+
+// sortedReleases returns the archived releases, newest first. Callers must
+// hold releasesMutex.
+func sortedReleases() []*archivedRelease {
+	list := make([]*archivedRelease, 0, len(releases))
+	for _, release := range releases {
+		list = append(list, release)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if c, ok := semver.Compare(list[i].Version, list[j].Version); ok && c != 0 {
+			return c > 0
+		}
+		return list[i].Created.After(list[j].Created)
+	})
+	return list
+}
+
+// releaseByVersion returns the release served as /versions/{version}: the
+// current one if it has that version, or else the newest upload of it.
+// Callers must hold releasesMutex.
+func releaseByVersion(version string) *archivedRelease {
+	if release, ok := releases[current.Current]; ok && release.Version == version {
+		return release
+	}
+	var newest *archivedRelease
+	for _, release := range releases {
+		if release.Version == version && (newest == nil || release.Created.After(newest.Created)) {
+			newest = release
+		}
+	}
+	return newest
+}
+
+// diskPath is where a file of the manifest is stored below filesDir.
+func diskPath(file MetaForFile) string {
+	if file.Source != "" {
+		return file.Source
+	}
+	return file.Path
+}
+
+// ensureCurrentArchived makes sure filesDir is an archived release of the
+// current version, at startup and when the version is set. It reuses the
+// current release, or else a release of the version with the same files,
+// and only archives filesDir as a new release when neither exists.
+func ensureCurrentArchived() {
+	cacheMutex.RLock()
+	v := versionCache
+	hash := manifestHash
+	cacheMutex.RUnlock()
+
+	releasesMutex.Lock()
+	if release, ok := releases[current.Current]; ok && release.Version == v && release.Hash == hash {
+		releasesMutex.Unlock()
+		return
+	}
+	if release := releaseByVersion(v); release != nil && release.Hash == hash {
+		setCurrent(release.ID, false)
+		releasesMutex.Unlock()
+		return
+	}
+	releasesMutex.Unlock()
+
+	release, err := archiveRelease(v, "", "")
+	if err != nil {
+		log.Printf("Failed to archive version %s: %v", v, err)
+		return
+	}
+	log.Printf("Archived version %s as release %s", v, release.ID)
+}
+
+// promoteRelease makes an archived release current and publishes its
+// version with the given policy. Its files are served from the blob store
+// at once; filesDir is left as it is, so promotions and rollbacks take the
+// same time however large the release is. rollback returns to the release
+// before the current one; see setCurrent.
+func promoteRelease(id string, policy versionPolicy, rollback bool) (*archivedRelease, error) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
+	releasesMutex.RLock()
+	release, ok := releases[id]
+	releasesMutex.RUnlock()
+	if !ok {
+		return nil, errReleaseNotFound
+	}
+
+	if err := setManifest(release.Files, release.Groups, release); err != nil {
+		return nil, err
+	}
+	if err := publishVersion(release.Version, policy); err != nil {
+		return nil, err
+	}
+
+	cacheMutex.RLock()
+	stale := filesDirHash
+	cacheMutex.RUnlock()
+	if stale == release.Hash {
+		stale = ""
+	}
+	releasesMutex.Lock()
+	current.Stale = stale
+	setCurrent(release.ID, rollback)
+	releasesMutex.Unlock()
+	endRollout(release.ID)
+	return release, nil
+}
+
+// staleRelease returns the current release while it is served from the blob
+// store because filesDir, whose manifest has the given hash, doesn't hold
+// its files. filesDir is only served again after an upload or a promotion
+// of its files; changes to it until then are logged, not served, so that
+// the current release and version keep naming what patchers get.
+func staleRelease(hash string) *archivedRelease {
+	releasesMutex.Lock()
+	defer releasesMutex.Unlock()
+	if current.Stale == "" {
+		return nil
+	}
+	release, ok := releases[current.Current]
+	if !ok {
+		current.Stale = ""
+		if err := saveCurrent(); err != nil {
+			log.Printf("Failed to save current release: %v", err)
+		}
+		return nil
+	}
+	if current.Stale != hash {
+		log.Printf("%s changed after release %s was promoted; upload the files to publish them", filesDir, release.ID)
+	}
+	return release
+}
+
+// clearStale records that filesDir holds the files to serve again, after an
+// upload replaced it.
+func clearStale() {
+	releasesMutex.Lock()
+	defer releasesMutex.Unlock()
+	if current.Stale == "" {
+		return
+	}
+	current.Stale = ""
+	if err := saveCurrent(); err != nil {
+		log.Printf("Failed to save current release: %v", err)
+	}
+}
+
+// previousRelease returns the ID of the newest release in the history that
+// still exists, or "" if there is none.
+func previousRelease() string {
+	releasesMutex.RLock()
+	defer releasesMutex.RUnlock()
+	for i := len(current.History) - 1; i >= 0; i-- {
+		if _, ok := releases[current.History[i]]; ok {
+			return current.History[i]
+		}
+	}
+	return ""
+}
+
+// versionsHandler lists the archived versions, newest first. A version
+// uploaded more than once is listed with the release releaseByVersion
+// serves.
+func versionsHandler(w http.ResponseWriter, r *http.Request) {
+	cacheMutex.RLock()
+	currentVersion := versionCache
+	cacheMutex.RUnlock()
+
+	releasesMutex.RLock()
+	list := []ReleaseInfo{}
+	listed := map[string]bool{}
+	for _, release := range sortedReleases() {
+		if listed[release.Version] {
+			continue
+		}
+		listed[release.Version] = true
+		release = releaseByVersion(release.Version)
+		list = append(list, ReleaseInfo{
+			Version:   release.Version,
+			Hash:      release.Hash,
+			TotalSize: release.TotalSize,
+			Created:   release.Created,
+			Current:   release.Version == currentVersion,
+		})
+	}
+	releasesMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"versions": list})
+}
+
+// releaseHandler serves an archived release the way the current one is
+// served:
+//
+//	/versions/{version}/meta          like /meta
+//	/versions/{version}/filesmeta     like /filesmeta
+//	/versions/{version}/files/{path}  like /files/{path}
+//	/versions/{version}/batch         like /batch
+//
+// The version is path-escaped, and the manifests accept the same os and
+// arch parameters.
+func releaseHandler(w http.ResponseWriter, r *http.Request) {
+	escaped, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/versions/"), "/")
+	version, err := url.PathUnescape(escaped)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	releasesMutex.RLock()
+	release := releaseByVersion(version)
+	releasesMutex.RUnlock()
+	if release == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "version not found"})
+		return
+	}
+	serveRelease(w, r, release, rest)
+}
+
+// serveRelease serves rest, one of meta, filesmeta, files/... and batch,
+// from an archived release.
+func serveRelease(w http.ResponseWriter, r *http.Request, release *archivedRelease, rest string) {
+	switch {
+	case rest == "filesmeta" && wantsStreamedManifest(r):
+		files, groups, _ := releaseFiles(release, r)
+		serveManifest(w, files, groups)
+	case rest == "meta" || rest == "filesmeta":
+		meta, filesMeta, err := releaseManifest(release, r)
+		if err != nil {
+			http.Error(w, "Meta data not available", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if rest == "meta" {
+			w.Write(meta)
+		} else {
+			w.Write(filesMeta)
+		}
+	case strings.HasPrefix(rest, "files/"):
+		name, err := url.PathUnescape(strings.TrimPrefix(rest, "files/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		hash, ok := release.blobs[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		file, err := os.Open(blobPath(hash))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, name, info.ModTime(), file)
+	case rest == "batch":
+		serveBatch(w, r, func(name string) string {
+			if hash, ok := release.blobs[name]; ok {
+				return blobPath(hash)
+			}
+			return ""
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// releaseManifest builds the /meta and /filesmeta payloads of an archived
+// release, filtered by platform as generateMetaFiles and platformMeta do for
+// the current one.
+func releaseManifest(release *archivedRelease, r *http.Request) (meta, filesMeta []byte, err error) {
+	files, groups, totalSize := releaseFiles(release, r)
+	overallHash, err := calculateOverallHash(files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta, err = json.Marshal(MetaData{Hash: overallHash, TotalSize: totalSize, Groups: groupIDs(groups)})
+	if err != nil {
+		return nil, nil, err
+	}
+	filesMeta, err = json.Marshal(MetaDataForFiles{Files: files, Groups: groups, Blobs: true})
+	return meta, filesMeta, err
+}
+
+// releaseFiles returns the files of an archived release for the platform
+// the request asks for, in manifest order, with their groups.
+func releaseFiles(release *archivedRelease, r *http.Request) (files []MetaForFile, groups []GroupMeta, totalSize int64) {
+	goos, goarch, scoped := platformQuery(r)
+	if scoped {
+		for _, file := range release.Files {
+			if matchesPlatform(file, goos, goarch) {
+				files = append(files, file)
+			}
+		}
+	} else {
+		files = unscopedFiles(release.Files)
+	}
+	// Releases archived before listings were sorted keep their old order
+	// in release.json.
+	sortManifest(files)
+	if scoped {
+		files = uniquePaths(files)
+	}
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	return files, summarizeGroups(release.Groups, files), totalSize
+}
+
+// adminVersionsHandler deletes every archived release of a version:
+// DELETE /admin/versions?version=1.2.0. The current release's version can't
+// be deleted.
+func adminVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	version := r.URL.Query().Get("version")
+
+	cacheMutex.RLock()
+	currentVersion := versionCache
+	cacheMutex.RUnlock()
+	if version == currentVersion {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "the current version can't be deleted"})
+		return
+	}
+
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	releasesMutex.Lock()
+	defer releasesMutex.Unlock()
+	defer collectBlobs()
+	rolling := rolloutReleaseID()
+	var removed int
+	for _, release := range sortedReleases() {
+		if release.Version != version || release.ID == current.Current || release.ID == rolling {
+			continue
+		}
+		if err := removeRelease(release); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to remove version"})
+			return
+		}
+		removed++
+	}
+	if removed == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "version not found"})
+		return
+	}
+	log.Printf("[admin] removed archived version %s", version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+}
+
+// removeRelease deletes an archived release. Its blobs stay until the next
+// collectBlobs. Callers must hold releasesMutex.
+func removeRelease(release *archivedRelease) error {
+	if err := os.RemoveAll(release.dir); err != nil {
+		log.Printf("[admin] removing release %s failed: %v", release.ID, err)
+		return err
+	}
+	delete(releases, release.ID)
+	log.Printf("[admin] removed release %s (version %s)", release.ID, release.Version)
+	return nil
+}
+
+// adminReleasesHandler manages the release history:
+//
+//	GET    /admin/releases          every release, newest upload first
+//	DELETE /admin/releases?id=...   remove a release that is neither current nor rolling out
+func adminReleasesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rolloutMutex.RLock()
+		state := rollout
+		rolloutMutex.RUnlock()
+		releasesMutex.RLock()
+		list := []AdminReleaseInfo{}
+		for _, release := range releases {
+			var percent int
+			if state != nil && state.Release == release.ID {
+				percent = state.Percent
+			}
+			list = append(list, AdminReleaseInfo{
+				ID:        release.ID,
+				Version:   release.Version,
+				Notes:     release.Notes,
+				Uploader:  release.Uploader,
+				Hash:      release.Hash,
+				TotalSize: release.TotalSize,
+				Files:     len(release.Files),
+				Created:   release.Created,
+				Current:   release.ID == current.Current,
+				Rollout:   percent,
+			})
+		}
+		history := append([]string{}, current.History...)
+		releasesMutex.RUnlock()
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Created.After(list[j].Created)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"releases": list, "history": history})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		publishMutex.Lock()
+		defer publishMutex.Unlock()
+		releasesMutex.Lock()
+		defer releasesMutex.Unlock()
+		release, ok := releases[id]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "release not found"})
+			return
+		}
+		if id == current.Current {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "the current release can't be deleted"})
+			return
+		}
+		if id == rolloutReleaseID() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "the release is being rolled out; abort the rollout first"})
+			return
+		}
+		if err := removeRelease(release); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to remove release"})
+			return
+		}
+		collectBlobs()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// adminPromoteHandler makes an archived release current:
+// POST /admin/releases/promote {"id": "...", "mandatory": false,
+// "minimumVersion": "..."}. The policy fields work as in /admin/version.
+func adminPromoteHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID             string  `json:"id"`
+		MinimumVersion *string `json:"minimumVersion"`
+		Mandatory      bool    `json:"mandatory"`
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "id is required"})
+		return
+	}
+	servePromotion(w, req.ID, req.Mandatory, req.MinimumVersion, false)
+}
+
+// adminRollbackHandler returns to the release that was current before the
+// current one: POST /admin/releases/rollback, with the same optional policy
+// fields as /admin/releases/promote.
+func adminRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MinimumVersion *string `json:"minimumVersion"`
+		Mandatory      bool    `json:"mandatory"`
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+	id := previousRelease()
+	if id == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no earlier release to roll back to"})
+		return
+	}
+	servePromotion(w, id, req.Mandatory, req.MinimumVersion, true)
+}
+
+func servePromotion(w http.ResponseWriter, id string, mandatory bool, minimumVersion *string, rollback bool) {
+	releasesMutex.RLock()
+	release, ok := releases[id]
+	releasesMutex.RUnlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "release not found"})
+		return
+	}
+	policy, err := nextVersionPolicy(release.Version, mandatory, minimumVersion)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	release, err = promoteRelease(id, policy, rollback)
+	if err != nil {
+		log.Printf("[admin] promoting release %s failed: %v", id, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to promote release"})
+		return
+	}
+	if rollback {
+		log.Printf("[admin] rolled back to release %s (version %s)", release.ID, release.Version)
+	} else {
+		log.Printf("[admin] promoted release %s (version %s)", release.ID, release.Version)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ok":      true,
+		"release": release.ID,
+		"version": release.Version,
+	})
+}
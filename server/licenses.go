@@ -0,0 +1,418 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// License grants access to the gated endpoints of one or more channels.
+type License struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// Channels lists the release channels the license is entitled to. An
+	// empty list grants every channel.
+	Channels []string   `json:"channels,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Revoked  bool       `json:"revoked"`
+	Created  time.Time  `json:"created"`
+}
+
+// licenseToken is an access token issued by /activate. Only its SHA-256 is
+// stored.
+type licenseToken struct {
+	License string    `json:"license"`
+	Issued  time.Time `json:"issued"`
+}
+
+// licenseStore is the persisted form of licenses.json.
+type licenseStore struct {
+	Licenses []License               `json:"licenses"`
+	Tokens   map[string]licenseToken `json:"tokens,omitempty"`
+}
+
+var (
+	licenses      licenseStore
+	licensesMutex sync.RWMutex
+	// requireLicense gates /meta, /filesmeta and /files/ behind a bearer
+	// token. It is set with REQUIRE_LICENSE=true.
+	requireLicense bool
+	// channel is the release channel this server serves, checked against
+	// license entitlements.
+	channel = "stable"
+)
+
+var activateLimiter = newRateLimiter(10, time.Minute) // 10 activations per minute per IP
+
+// maxLicenseTokens is how many tokens a license keeps. Activating it again
+// replaces the oldest, so reinstalls don't grow licenses.json forever.
+const maxLicenseTokens = 10
+
+func loadLicenses() error {
+	data, err := os.ReadFile(licensesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	licensesMutex.Lock()
+	defer licensesMutex.Unlock()
+	return json.Unmarshal(data, &licenses)
+}
+
+// saveLicenses writes licenses.json through a temp file, readable by the
+// server's user only. Callers must hold licensesMutex.
+func saveLicenses() error {
+	data, err := json.MarshalIndent(licenses, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := licensesFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, licensesFile)
+}
+
+// newLicenseKey returns a key such as "K7QF-2MZD-X4PA-9RWE".
+func newLicenseKey() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	s := base32.StdEncoding.EncodeToString(b)
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+}
+
+func newLicenseToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeLicenseKey(key string) string {
+	return strings.ToUpper(strings.TrimSpace(key))
+}
+
+// findLicense returns the index of the license with the given key, or -1.
+// Callers must hold licensesMutex.
+func findLicense(key string) int {
+	for i, license := range licenses.Licenses {
+		if license.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkLicense returns why a license can't be used, or "" if it is valid
+// for this server's channel.
+func checkLicense(license License) string {
+	if license.Revoked {
+		return "license revoked"
+	}
+	if license.Expires != nil && license.Expires.Before(time.Now()) {
+		return "license expired"
+	}
+	if len(license.Channels) > 0 {
+		for _, c := range license.Channels {
+			if c == channel {
+				return ""
+			}
+		}
+		return "license does not include the " + channel + " channel"
+	}
+	return ""
+}
+
+// licenseAuth requires a valid bearer token when license mode is enabled.
+func licenseAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requireLicense {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="license"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "license required"})
+			return
+		}
+
+		licensesMutex.RLock()
+		var reason string
+		record, found := licenses.Tokens[hashToken(token)]
+		if i := findLicense(record.License); found && i >= 0 {
+			reason = checkLicense(licenses.Licenses[i])
+		} else {
+			found = false
+		}
+		licensesMutex.RUnlock()
+
+		if !found {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="license", error="invalid_token"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
+			return
+		}
+		if reason != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": reason})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// activateHandler exchanges a license key for an access token.
+func activateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	ip := r.RemoteAddr
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		ip = strings.SplitN(fwd, ",", 2)[0]
+	}
+	if !activateLimiter.allow(strings.TrimSpace(ip)) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": "too many requests, try again later"})
+		return
+	}
+
+	var req struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+
+	key := normalizeLicenseKey(req.Key)
+	licensesMutex.Lock()
+	i := findLicense(key)
+	if i < 0 {
+		licensesMutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "unknown license key"})
+		return
+	}
+	license := licenses.Licenses[i]
+	if reason := checkLicense(license); reason != "" {
+		licensesMutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": reason})
+		return
+	}
+
+	token := newLicenseToken()
+	if licenses.Tokens == nil {
+		licenses.Tokens = map[string]licenseToken{}
+	}
+	licenses.Tokens[hashToken(token)] = licenseToken{License: key, Issued: time.Now().UTC()}
+	pruneLicenseTokens(key)
+	err := saveLicenses()
+	licensesMutex.Unlock()
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to save activation"})
+		return
+	}
+	log.Printf("license %s activated", license.Name)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":    token,
+		"name":     license.Name,
+		"channels": license.Channels,
+		"expires":  license.Expires,
+	})
+}
+
+// pruneLicenseTokens drops the oldest tokens of a license beyond
+// maxLicenseTokens. Callers must hold licensesMutex.
+func pruneLicenseTokens(key string) {
+	var hashes []string
+	for hash, token := range licenses.Tokens {
+		if token.License == key {
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) <= maxLicenseTokens {
+		return
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return licenses.Tokens[hashes[i]].Issued.Before(licenses.Tokens[hashes[j]].Issued)
+	})
+	for _, hash := range hashes[:len(hashes)-maxLicenseTokens] {
+		delete(licenses.Tokens, hash)
+	}
+}
+
+// adminLicensesHandler lists (GET), creates or updates (POST) and deletes
+// (DELETE ?key=) licenses. Deleting a license also drops its tokens.
+func adminLicensesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		licensesMutex.RLock()
+		defer licensesMutex.RUnlock()
+		activations := map[string]int{}
+		for _, token := range licenses.Tokens {
+			activations[token.License]++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"licenses":    licenses.Licenses,
+			"activations": activations,
+			"required":    requireLicense,
+			"channel":     channel,
+		})
+
+	case http.MethodPost:
+		var license License
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&license); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid license"})
+			return
+		}
+		license.Key = normalizeLicenseKey(license.Key)
+		license.Name = strings.TrimSpace(license.Name)
+
+		licensesMutex.Lock()
+		if license.Key == "" {
+			license.Key = newLicenseKey()
+		}
+		if i := findLicense(license.Key); i >= 0 {
+			license.Created = licenses.Licenses[i].Created
+			licenses.Licenses[i] = license
+		} else {
+			license.Created = time.Now().UTC()
+			licenses.Licenses = append(licenses.Licenses, license)
+		}
+		err := saveLicenses()
+		licensesMutex.Unlock()
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to save licenses"})
+			return
+		}
+		log.Printf("[admin] license %s saved", license.Name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "license": license})
+
+	case http.MethodDelete:
+		key := normalizeLicenseKey(r.URL.Query().Get("key"))
+		licensesMutex.Lock()
+		i := findLicense(key)
+		var err error
+		if i >= 0 {
+			licenses.Licenses = append(licenses.Licenses[:i], licenses.Licenses[i+1:]...)
+			for hash, token := range licenses.Tokens {
+				if token.License == key {
+					delete(licenses.Tokens, hash)
+				}
+			}
+			err = saveLicenses()
+		}
+		licensesMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "license not found"})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to save licenses"})
+			return
+		}
+		log.Printf("[admin] license %s deleted", key)
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// adminRevokeLicenseHandler revokes (or with "revoked": false restores) a
+// license. Tokens issued for it stop working immediately.
+func adminRevokeLicenseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	req := struct {
+		Key     string `json:"key"`
+		Revoked *bool  `json:"revoked"`
+	}{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+	revoked := req.Revoked == nil || *req.Revoked
+
+	key := normalizeLicenseKey(req.Key)
+	licensesMutex.Lock()
+	i := findLicense(key)
+	var err error
+	if i >= 0 {
+		licenses.Licenses[i].Revoked = revoked
+		err = saveLicenses()
+	}
+	licensesMutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if i < 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "license not found"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to save licenses"})
+		return
+	}
+	log.Printf("[admin] license %s revoked=%v", key, revoked)
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "revoked": revoked})
+}
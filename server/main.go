@@ -4,33 +4,46 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"ppatcher/semver"
 )
 
 type MetaData struct {
-	Hash      string `json:"hash"`
-	TotalSize int64  `json:"totalSize"`
+	Hash      string   `json:"hash"`
+	TotalSize int64    `json:"totalSize"`
+	Groups    []string `json:"groups,omitempty"`
 }
 
 type MetaDataForFiles struct {
-	Files []MetaForFile `json:"files"`
+	Files  []MetaForFile `json:"files"`
+	Groups []GroupMeta   `json:"groups,omitempty"`
+	// Blobs tells patchers that every file can be fetched by its hash from
+	// /blobs/.
+	Blobs bool `json:"blobs"`
 }
 
 type MetaForFile struct {
-	Hash string `json:"hash"`
-	Path string `json:"path"`
-	Size int64  `json:"size"`
+	Hash   string `json:"hash"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	Source string `json:"source,omitempty"`
+	Group  string `json:"group,omitempty"`
 }
 
 var (
@@ -38,6 +51,18 @@ var (
 	filesmetaFile = "filesmeta.json"
 	versionFile   = "version.txt"
 	adminKeyFile  = "adminkey.txt"
+	platformsFile = "platforms.json"
+	groupsFile    = "groups.json"
+	newsFile      = "news.json"
+	licensesFile  = "licenses.json"
+	maintenanceFile = "maintenance.json"
+	translationsFile = "translations.json"
+	versionPolicyFile = "versionpolicy.json"
+	versionsDir = "versions"
+	blobsDir = "blobs"
+	currentFile = "current.json"
+	rolloutFile = "rollout.json"
+	telemetryFile = "telemetry.jsonl"
 )
 
 var (
@@ -46,6 +71,16 @@ var (
 	adminKey        string
 	metaCache       []byte
 	filesMetaCache  []byte
+	filesMetaList   []MetaForFile
+	unscopedList    []MetaForFile
+	unscopedGroups  []GroupMeta
+	groupDefsCache  []groupDef
+	metaGeneration  int
+	manifestHash    string
+	// filesDirHash is the manifest hash of filesDir, which differs from
+	// manifestHash while servedRelease is set.
+	filesDirHash    string
+	servedRelease   *archivedRelease
 	versionCache    string
 	cacheMutex      sync.RWMutex
 	bufferPool     = sync.Pool{
@@ -139,11 +174,6 @@ func main() {
 		log.Fatalf("Failed to create files directory: %v", err)
 	}
 
-	// Generate initial meta files
-	if err := generateMetaFiles(); err != nil {
-		log.Fatalf("Failed to generate initial meta files: %v", err)
-	}
-
 	// Load version
 	if data, err := os.ReadFile(versionFile); err == nil {
 		versionCache = strings.TrimSpace(string(data))
@@ -151,6 +181,44 @@ func main() {
 	if versionCache == "" {
 		versionCache = "1.0.0"
 	}
+	if err := loadVersionPolicy(); err != nil {
+		log.Printf("Failed to load version policy: %v", err)
+	}
+
+	if keep, err := strconv.Atoi(os.Getenv("KEEP_VERSIONS")); err == nil {
+		keepVersions = keep
+	}
+	if err := loadReleases(); err != nil {
+		log.Printf("Failed to load archived releases: %v", err)
+	}
+
+	// Generate initial meta files
+	if err := generateMetaFiles(); err != nil {
+		log.Fatalf("Failed to generate initial meta files: %v", err)
+	}
+	ensureCurrentArchived()
+	if err := loadRollout(); err != nil {
+		log.Printf("Failed to load rollout: %v", err)
+	}
+
+	if err := loadNews(); err != nil {
+		log.Printf("Failed to load news: %v", err)
+	}
+
+	requireLicense = os.Getenv("REQUIRE_LICENSE") == "true"
+	if envChannel := os.Getenv("CHANNEL"); envChannel != "" {
+		channel = envChannel
+	}
+	if err := loadLicenses(); err != nil {
+		log.Fatalf("Failed to load licenses: %v", err)
+	}
+	if requireLicense {
+		log.Printf("License required for downloads (channel %s)", channel)
+	}
+
+	if err := loadMaintenance(); err != nil {
+		log.Printf("Failed to load maintenance state: %v", err)
+	}
 
 	// Start file watcher
 	go watchFiles()
@@ -158,15 +226,36 @@ func main() {
 	// Set up HTTP handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
-	mux.HandleFunc("/meta", metaHandler)
-	mux.HandleFunc("/filesmeta", filesmetaHandler)
+	mux.Handle("/meta", licenseAuth(withRollout(http.HandlerFunc(metaHandler))))
+	mux.Handle("/filesmeta", licenseAuth(withRollout(http.HandlerFunc(filesmetaHandler))))
 	mux.HandleFunc("/version", versionHandler)
-	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(filesDir))))
+	mux.HandleFunc("/news", newsHandler)
+	mux.HandleFunc("/activate", activateHandler)
+	mux.HandleFunc("/events", eventsHandler)
+	mux.HandleFunc("/translations", translationsHandler)
+	mux.HandleFunc("/versions", versionsHandler)
+	mux.Handle("/versions/", licenseAuth(http.HandlerFunc(releaseHandler)))
+	mux.HandleFunc("/telemetry", telemetryHandler)
+	mux.Handle("/batch", licenseAuth(withRollout(http.HandlerFunc(batchHandler))))
+	mux.Handle("/blobs/", licenseAuth(http.HandlerFunc(blobsHandler)))
+	mux.Handle("/files/", licenseAuth(withRollout(http.HandlerFunc(filesHandler))))
 
 	// Admin endpoints (basic auth + rate limit)
 	mux.HandleFunc("/admin/upload", adminAuth(adminUploadHandler))
 	mux.HandleFunc("/admin/check-space", adminAuth(adminCheckSpaceHandler))
 	mux.HandleFunc("/admin/version", adminAuth(adminVersionHandler))
+	mux.HandleFunc("/admin/news", adminAuth(adminNewsHandler))
+	mux.HandleFunc("/admin/changelog", adminAuth(adminChangelogHandler))
+	mux.HandleFunc("/admin/licenses", adminAuth(adminLicensesHandler))
+	mux.HandleFunc("/admin/licenses/revoke", adminAuth(adminRevokeLicenseHandler))
+	mux.HandleFunc("/admin/maintenance", adminAuth(adminMaintenanceHandler))
+	mux.HandleFunc("/admin/versions", adminAuth(adminVersionsHandler))
+	mux.HandleFunc("/admin/releases", adminAuth(adminReleasesHandler))
+	mux.HandleFunc("/admin/releases/promote", adminAuth(adminPromoteHandler))
+	mux.HandleFunc("/admin/releases/rollback", adminAuth(adminRollbackHandler))
+	mux.HandleFunc("/admin/rollout", adminAuth(adminRolloutHandler))
+	mux.HandleFunc("/admin/rollout/pause", adminAuth(adminRolloutPauseHandler))
+	mux.HandleFunc("/admin/stats", adminAuth(adminStatsHandler))
 
 	log.Printf("Server starting on port %s", port)
 	log.Fatal(http.ListenAndServe(port, withCORS(mux)))
@@ -274,28 +363,73 @@ func watchFiles() {
 	}
 }
 
+// generateMetaFiles builds the manifest of filesDir and serves it, unless
+// filesDir still holds the files of a release that was promoted away from.
 func generateMetaFiles() error {
 	// Calculate file metadata
-	filesMeta, totalSize, err := calculateFilesMeta()
+	filesMeta, _, err := calculateFilesMeta(filesDir)
 	if err != nil {
 		return err
 	}
 
+	defs, err := loadGroupDefs()
+	if err != nil {
+		return err
+	}
+	assignGroups(filesMeta, defs)
+
+	unscoped := unscopedFiles(filesMeta)
+	sortManifest(unscoped)
+	hash, err := calculateOverallHash(unscoped)
+	if err != nil {
+		return err
+	}
+	cacheMutex.Lock()
+	filesDirHash = hash
+	cacheMutex.Unlock()
+
+	if release := staleRelease(hash); release != nil {
+		log.Printf("%s isn't live, serving release %s from the blob store", filesDir, release.ID)
+		return setManifest(release.Files, release.Groups, release)
+	}
+	return setManifest(filesMeta, defs, nil)
+}
+
+// setManifest serves files as the current release: files of filesDir, or
+// of release when that is served from the blob store.
+func setManifest(filesMeta []MetaForFile, defs []groupDef, release *archivedRelease) error {
+	filesMeta = append([]MetaForFile(nil), filesMeta...)
+	sortManifest(filesMeta)
+	var totalSize int64
+	for _, fileMeta := range filesMeta {
+		totalSize += fileMeta.Size
+	}
+
+	// Clients that don't ask for a platform get every file under its
+	// on-disk path, exactly as before platform tagging existed.
+	unscoped := unscopedFiles(filesMeta)
+	sortManifest(unscoped)
+
 	// Calculate overall hash
-	overallHash, err := calculateOverallHash(filesMeta)
+	overallHash, err := calculateOverallHash(unscoped)
 	if err != nil {
 		return err
 	}
 
+	groups := summarizeGroups(defs, unscoped)
+
 	// Create meta data
 	meta := MetaData{
 		Hash:      overallHash,
 		TotalSize: totalSize,
+		Groups:    groupIDs(groups),
 	}
 
 	// Create files meta data
 	filesMetaData := MetaDataForFiles{
-		Files: filesMeta,
+		Files:  unscoped,
+		Groups: groups,
+		Blobs:  true,
 	}
 
 	// Marshal to JSON
@@ -313,8 +447,26 @@ func generateMetaFiles() error {
 	cacheMutex.Lock()
 	metaCache = metaJSON
 	filesMetaCache = filesMetaJSON
+	filesMetaList = filesMeta
+	unscopedList = unscoped
+	unscopedGroups = groups
+	groupDefsCache = defs
+	metaGeneration++
+	platformCache = map[string]*platformCacheEntry{}
+	platformTagsCache = collectPlatformTags(filesMeta)
+	currentBlobs = nil
+	if release == nil {
+		currentBlobs = blobPaths(filesMeta)
+	}
+	servedRelease = release
+	changed := manifestHash != overallHash
+	manifestHash = overallHash
 	cacheMutex.Unlock()
 
+	if changed {
+		events.publish("manifest", map[string]interface{}{"hash": overallHash, "totalSize": totalSize})
+	}
+
 	// Write to files
 	if err := os.WriteFile(metaFile, metaJSON, 0644); err != nil {
 		return err
@@ -328,11 +480,17 @@ func generateMetaFiles() error {
 	return nil
 }
 
-func calculateFilesMeta() ([]MetaForFile, int64, error) {
+// calculateFilesMeta lists the files below dir as manifest entries.
+func calculateFilesMeta(dir string) ([]MetaForFile, int64, error) {
 	var filesMeta []MetaForFile
 	var totalSize int64
 
-	err := filepath.Walk(filesDir, func(path string, info os.FileInfo, err error) error {
+	rules, err := loadPlatformRules()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -349,7 +507,7 @@ func calculateFilesMeta() ([]MetaForFile, int64, error) {
 		}
 
 		// Get relative path
-		relPath, err := filepath.Rel(filesDir, path)
+		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
 			return err
 		}
@@ -362,6 +520,7 @@ func calculateFilesMeta() ([]MetaForFile, int64, error) {
 			Path: relPath,
 			Size: info.Size(),
 		}
+		applyPlatform(&fileMeta, rules)
 
 		filesMeta = append(filesMeta, fileMeta)
 		totalSize += info.Size()
@@ -403,22 +562,32 @@ func calculateOverallHash(filesMeta []MetaForFile) (string, error) {
 	hash := md5.New()
 
 	for _, fileMeta := range filesMeta {
-		// Include file path, hash, and size in the overall hash calculation
-		hash.Write([]byte(fileMeta.Path))
-		hash.Write([]byte(fileMeta.Hash))
-
-		// Write size as 8 bytes (int64)
-		size := fileMeta.Size
-		for i := 0; i < 8; i++ {
-			hash.Write([]byte{byte(size)})
-			size >>= 8
-		}
+		hashEntry(hash, fileMeta)
 	}
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// platformQuery returns the os and arch query parameters of a manifest
+// request. Both are empty for clients that don't scope their requests.
+func platformQuery(r *http.Request) (goos, goarch string, scoped bool) {
+	goos = r.URL.Query().Get("os")
+	goarch = r.URL.Query().Get("arch")
+	return goos, goarch, goos != "" || goarch != ""
+}
+
 func metaHandler(w http.ResponseWriter, r *http.Request) {
+	if goos, goarch, scoped := platformQuery(r); scoped {
+		entry, err := platformMeta(goos, goarch)
+		if err != nil {
+			http.Error(w, "Meta data not available", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry.meta)
+		return
+	}
+
 	cacheMutex.RLock()
 	defer cacheMutex.RUnlock()
 
@@ -432,6 +601,29 @@ func metaHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func filesmetaHandler(w http.ResponseWriter, r *http.Request) {
+	if goos, goarch, scoped := platformQuery(r); scoped {
+		entry, err := platformMeta(goos, goarch)
+		if err != nil {
+			http.Error(w, "Files meta data not available", http.StatusInternalServerError)
+			return
+		}
+		if wantsStreamedManifest(r) {
+			serveManifest(w, entry.files, entry.groups)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry.filesMeta)
+		return
+	}
+
+	if wantsStreamedManifest(r) {
+		cacheMutex.RLock()
+		files, groups := unscopedList, unscopedGroups
+		cacheMutex.RUnlock()
+		serveManifest(w, files, groups)
+		return
+	}
+
 	cacheMutex.RLock()
 	defer cacheMutex.RUnlock()
 
@@ -444,14 +636,34 @@ func filesmetaHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(filesMetaCache)
 }
 
+// versionHandler serves the current release version together with the
+// oldest version patchers may launch and whether the release is mandatory.
+// Patchers in a rollout get the version being rolled out, never mandatory.
 func versionHandler(w http.ResponseWriter, r *http.Request) {
 	cacheMutex.RLock()
 	v := versionCache
+	policy := policyCache
 	cacheMutex.RUnlock()
+	varyRollout(w)
+	if release := rolloutRelease(r); release != nil {
+		v = release.Version
+		policy.Mandatory = false
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"version": v})
+	json.NewEncoder(w).Encode(versionPayload(v, policy))
 }
 
+func versionPayload(v string, policy versionPolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"version":        v,
+		"minimumVersion": policy.MinimumVersion,
+		"mandatory":      policy.Mandatory,
+	}
+}
+
+// adminVersionHandler sets the release version. "mandatory" applies to this
+// release only; "minimumVersion" is kept until it is sent again, and ""
+// removes it.
 func adminVersionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -460,7 +672,9 @@ func adminVersionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		Version string `json:"version"`
+		Version        string  `json:"version"`
+		MinimumVersion *string `json:"minimumVersion"`
+		Mandatory      bool    `json:"mandatory"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Version) == "" {
 		w.Header().Set("Content-Type", "application/json")
@@ -469,18 +683,65 @@ func adminVersionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	v := strings.TrimSpace(req.Version)
-	if err := os.WriteFile(versionFile, []byte(v), 0644); err != nil {
+
+	policy, err := nextVersionPolicy(v, req.Mandatory, req.MinimumVersion)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if err := publishVersion(v, policy); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "failed to write version"})
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
+	ensureCurrentArchived()
+	payload := versionPayload(v, policy)
+	payload["ok"] = "true"
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// nextVersionPolicy returns the policy for publishing version v: mandatory
+// applies to v only, and minimumVersion replaces the kept one when it is
+// set. It must be a semantic version no higher than v.
+func nextVersionPolicy(v string, mandatory bool, minimumVersion *string) (versionPolicy, error) {
+	cacheMutex.RLock()
+	policy := policyCache
+	cacheMutex.RUnlock()
+	policy.Mandatory = mandatory
+	if minimumVersion != nil {
+		policy.MinimumVersion = strings.TrimSpace(*minimumVersion)
+	}
+	if policy.MinimumVersion != "" {
+		c, ok := semver.Compare(policy.MinimumVersion, v)
+		if !ok || c > 0 {
+			return policy, errors.New("minimumVersion and version must be semantic versions, with minimumVersion not above version")
+		}
+	}
+	return policy, nil
+}
+
+// publishVersion makes v the current version with policy and announces it
+// to connected patchers.
+func publishVersion(v string, policy versionPolicy) error {
+	if err := os.WriteFile(versionFile, []byte(v), 0644); err != nil {
+		return errors.New("failed to write version")
+	}
+	if err := saveVersionPolicy(policy); err != nil {
+		return errors.New("failed to write version policy")
+	}
 	cacheMutex.Lock()
 	versionCache = v
+	policyCache = policy
 	cacheMutex.Unlock()
-	log.Printf("Version updated to %s", v)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"ok": "true", "version": v})
+	events.publish("version", versionPayload(v, policy))
+	log.Printf("Version updated to %s (minimum %q, mandatory %v)", v, policy.MinimumVersion, policy.Mandatory)
+	return nil
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -497,7 +758,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -545,6 +806,12 @@ func adminAuth(next http.HandlerFunc) http.HandlerFunc {
 
 // ---------- Admin upload handler ----------
 
+// adminUploadHandler replaces the files with the contents of a zip archive
+// and stores them as a new release. The optional form fields "version",
+// "notes" and "uploader" describe the release; with a version it is
+// published as /admin/version would. With "stage" set to true the release
+// is only archived, for a rollout or a later promotion, and what is served
+// stays as it is.
 func adminUploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Content-Type", "application/json")
@@ -572,6 +839,29 @@ func adminUploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	version := strings.TrimSpace(r.FormValue("version"))
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	uploader := strings.TrimSpace(r.FormValue("uploader"))
+	if uploader == "" {
+		uploader = "admin"
+	}
+	stage := r.FormValue("stage") == "true"
+	if stage && version == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "a staged upload needs a version"})
+		return
+	}
+	var policy versionPolicy
+	if version != "" {
+		if policy, err = nextVersionPolicy(version, false, nil); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
 	filename := filepath.Base(header.Filename)
 	ext := strings.ToLower(filepath.Ext(filename))
 	if ext != ".zip" {
@@ -581,6 +871,9 @@ func adminUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
 	// Save to a temp file next to filesDir
 	tmpPath := filepath.Join(filepath.Dir(filesDir), "upload-tmp"+ext)
 	tmpFile, err := os.Create(tmpPath)
@@ -619,20 +912,36 @@ func adminUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Atomic swap: remove old files, rename new into place
-	oldDir := filepath.Dir(filesDir) + "/files-old"
-	os.RemoveAll(oldDir)
-	os.Rename(filesDir, oldDir)
-	if err := os.Rename(tmpExtract, filesDir); err != nil {
-		// Rollback if rename fails
-		os.Rename(oldDir, filesDir)
-		log.Printf("[admin] swap failed: %v", err)
+	if stage {
+		release, err := stageRelease(tmpExtract, version, notes, uploader)
+		os.RemoveAll(tmpExtract)
+		if err != nil {
+			log.Printf("[admin] staging the upload failed: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to store the release"})
+			return
+		}
+		log.Printf("[admin] staged upload as release %s (version %s)", release.ID, release.Version)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":        true,
+			"staged":    true,
+			"files":     len(release.Files),
+			"totalSize": release.TotalSize,
+			"release":   release.ID,
+			"version":   release.Version,
+		})
+		return
+	}
+
+	if err := replaceFilesDir(tmpExtract); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "failed to replace files"})
 		return
 	}
-	os.RemoveAll(oldDir)
+	clearStale()
 
 	// Count extracted files
 	var fileCount int
@@ -652,12 +961,51 @@ func adminUploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[admin] replaced files: %d files, %d bytes total", fileCount, totalSize)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"ok":        true,
 		"files":     fileCount,
 		"totalSize": totalSize,
-	})
+	}
+
+	if version != "" {
+		if err := publishVersion(version, policy); err != nil {
+			log.Printf("[admin] warning: publishing version %s failed: %v", version, err)
+		}
+	}
+	cacheMutex.RLock()
+	version = versionCache
+	cacheMutex.RUnlock()
+	// The files are live either way; without an archive they just can't be
+	// promoted again later.
+	if release, err := archiveRelease(version, notes, uploader); err != nil {
+		log.Printf("[admin] warning: archiving the upload failed: %v", err)
+		resp["warning"] = "the upload could not be archived as a release"
+	} else {
+		log.Printf("[admin] stored upload as release %s (version %s)", release.ID, release.Version)
+		resp["release"] = release.ID
+		resp["version"] = release.Version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// replaceFilesDir swaps dir into place as filesDir and deletes the old
+// files. Callers must hold publishMutex.
+func replaceFilesDir(dir string) error {
+	// Atomic swap: remove old files, rename new into place
+	oldDir := filepath.Dir(filesDir) + "/files-old"
+	os.RemoveAll(oldDir)
+	os.Rename(filesDir, oldDir)
+	if err := os.Rename(dir, filesDir); err != nil {
+		// Rollback if rename fails
+		os.Rename(oldDir, filesDir)
+		os.RemoveAll(dir)
+		log.Printf("[admin] swap failed: %v", err)
+		return err
+	}
+	os.RemoveAll(oldDir)
+	return nil
 }
 
 // extractArchive extracts a zip archive using the system unzip command.
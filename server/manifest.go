@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// The streamed manifest is an alternative to the /filesmeta JSON for very
+// large installs. It is sent for /filesmeta?format=v1 with the content type
+// manifestContentType, and is a gzip stream of:
+//
+//	"PPM1"                  magic and format version
+//	bytes  header           JSON object, {"groups": [...], "blobs": true} as in /filesmeta
+//	entries                 in manifest order, each:
+//	  uvarint shared        bytes shared with the previous entry's path
+//	  bytes   suffix        the rest of the path
+//	  bytes   hash          raw md5 of the file
+//	  uvarint size
+//	  bytes   os, arch, source, group
+//	uvarint 0, bytes ""     the end: an empty path
+//	[16]byte                the overall hash of all entries, as in /meta
+//
+// where bytes is a uvarint length followed by that many bytes. Entries are
+// sorted by path, byte-wise, and every path is listed once: of overlapping
+// platform files only the most specific one is sent. Readers reject streams
+// that are out of order, list a path twice or whose overall hash doesn't
+// match.
+const (
+	manifestContentType = "application/x-ppatcher-manifest"
+	manifestMagic       = "PPM1"
+)
+
+// sortManifest puts files in manifest order. Every listing the server sends
+// and every overall hash it calculates uses that order, so neither depends
+// on the order files were found on disk.
+func sortManifest(files []MetaForFile) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Path != files[j].Path {
+			return files[i].Path < files[j].Path
+		}
+		return files[i].Source < files[j].Source
+	})
+}
+
+// wantsStreamedManifest reports whether the request asks for the streamed
+// manifest instead of JSON.
+func wantsStreamedManifest(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "v1"
+}
+
+// serveManifest streams files and groups to w. Errors after the headers
+// are sent can only be logged; the client notices the missing trailer.
+func serveManifest(w http.ResponseWriter, files []MetaForFile, groups []GroupMeta) {
+	w.Header().Set("Content-Type", manifestContentType)
+	if err := writeManifest(w, files, groups); err != nil {
+		log.Printf("Streaming manifest failed: %v", err)
+	}
+}
+
+// writeManifest writes files, which must be in manifest order, in the
+// streamed format.
+func writeManifest(w io.Writer, files []MetaForFile, groups []GroupMeta) error {
+	zw, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	e := &manifestEncoder{w: bufio.NewWriter(zw)}
+
+	header, err := json.Marshal(struct {
+		Groups []GroupMeta `json:"groups,omitempty"`
+		Blobs  bool        `json:"blobs"`
+	}{groups, true})
+	if err != nil {
+		return err
+	}
+	e.w.WriteString(manifestMagic)
+	e.bytes(header)
+
+	overall := md5.New()
+	previous := ""
+	for _, file := range files {
+		sum, err := hex.DecodeString(file.Hash)
+		if err != nil {
+			return fmt.Errorf("%s: invalid hash %q", file.Path, file.Hash)
+		}
+		if file.Path == "" || file.Size < 0 {
+			return fmt.Errorf("invalid manifest entry %q", file.Path)
+		}
+		if file.Path <= previous {
+			return fmt.Errorf("manifest out of order or duplicate at %q", file.Path)
+		}
+		shared := 0
+		for shared < len(previous) && shared < len(file.Path) && previous[shared] == file.Path[shared] {
+			shared++
+		}
+		e.uvarint(uint64(shared))
+		e.string(file.Path[shared:])
+		e.bytes(sum)
+		e.uvarint(uint64(file.Size))
+		e.string(file.OS)
+		e.string(file.Arch)
+		e.string(file.Source)
+		e.string(file.Group)
+		hashEntry(overall, file)
+		previous = file.Path
+	}
+	e.uvarint(0)
+	e.string("")
+	e.w.Write(overall.Sum(nil))
+
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.w.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// manifestEncoder remembers the first write error, so that writeManifest
+// only checks once.
+type manifestEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *manifestEncoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	if _, err := e.w.Write(e.buf[:n]); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *manifestEncoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	if _, err := e.w.Write(b); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *manifestEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	if _, err := e.w.WriteString(s); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// hashEntry adds a file to an overall hash.
+func hashEntry(h hash.Hash, fileMeta MetaForFile) {
+	// Include file path, hash, and size in the overall hash calculation
+	h.Write([]byte(fileMeta.Path))
+	h.Write([]byte(fileMeta.Hash))
+
+	// Write size as 8 bytes (int64)
+	size := fileMeta.Size
+	for i := 0; i < 8; i++ {
+		h.Write([]byte{byte(size)})
+		size >>= 8
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewsItem is an announcement shown in the launcher. Body is Markdown.
+type NewsItem struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Severity  string     `json:"severity"`
+	Published time.Time  `json:"published"`
+	Expires   *time.Time `json:"expires,omitempty"`
+}
+
+// newsStore is the persisted form of news.json.
+type newsStore struct {
+	Items []NewsItem `json:"items"`
+	// Changelogs holds the Markdown patch notes per release version.
+	Changelogs map[string]string `json:"changelogs,omitempty"`
+}
+
+var (
+	news      newsStore
+	newsMutex sync.RWMutex
+)
+
+var newsSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+func loadNews() error {
+	data, err := os.ReadFile(newsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	newsMutex.Lock()
+	defer newsMutex.Unlock()
+	return json.Unmarshal(data, &news)
+}
+
+// saveNews writes news.json through a temp file so a crash never leaves a
+// truncated store behind. Callers must hold newsMutex.
+func saveNews() error {
+	data, err := json.MarshalIndent(news, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := newsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, newsFile)
+}
+
+func newNewsID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newsHandler serves the current feed: unexpired items, newest first, and
+// the changelog of the current release.
+func newsHandler(w http.ResponseWriter, r *http.Request) {
+	cacheMutex.RLock()
+	version := versionCache
+	cacheMutex.RUnlock()
+
+	now := time.Now()
+	newsMutex.RLock()
+	items := make([]NewsItem, 0, len(news.Items))
+	for _, item := range news.Items {
+		if item.Expires != nil && item.Expires.Before(now) {
+			continue
+		}
+		items = append(items, item)
+	}
+	changelog := news.Changelogs[version]
+	newsMutex.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Published.After(items[j].Published)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":     items,
+		"version":   version,
+		"changelog": changelog,
+	})
+}
+
+// adminNewsHandler lists (GET), creates or updates (POST) and deletes
+// (DELETE ?id=) news items.
+func adminNewsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		newsMutex.RLock()
+		defer newsMutex.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(news)
+
+	case http.MethodPost:
+		var item NewsItem
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&item); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid news item"})
+			return
+		}
+		item.Title = strings.TrimSpace(item.Title)
+		if item.Title == "" && strings.TrimSpace(item.Body) == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "title or body is required"})
+			return
+		}
+		if item.Severity == "" {
+			item.Severity = "info"
+		}
+		if !newsSeverities[item.Severity] {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "severity must be info, warning or critical"})
+			return
+		}
+		if item.Published.IsZero() {
+			item.Published = time.Now().UTC()
+		}
+
+		newsMutex.Lock()
+		if item.ID == "" {
+			item.ID = newNewsID()
+			news.Items = append(news.Items, item)
+		} else {
+			replaced := false
+			for i := range news.Items {
+				if news.Items[i].ID == item.ID {
+					news.Items[i] = item
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				news.Items = append(news.Items, item)
+			}
+		}
+		err := saveNews()
+		newsMutex.Unlock()
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to save news"})
+			return
+		}
+		log.Printf("[admin] news item %s saved", item.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "item": item})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		newsMutex.Lock()
+		kept := news.Items[:0]
+		found := false
+		for _, item := range news.Items {
+			if item.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, item)
+		}
+		news.Items = kept
+		err := saveNews()
+		newsMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "news item not found"})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to save news"})
+			return
+		}
+		log.Printf("[admin] news item %s deleted", id)
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// adminChangelogHandler sets the Markdown changelog of a release. The
+// version defaults to the current one; an empty changelog removes it.
+func adminChangelogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	var req struct {
+		Version   string `json:"version"`
+		Changelog string `json:"changelog"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+	version := strings.TrimSpace(req.Version)
+	if version == "" {
+		cacheMutex.RLock()
+		version = versionCache
+		cacheMutex.RUnlock()
+	}
+
+	newsMutex.Lock()
+	if news.Changelogs == nil {
+		news.Changelogs = map[string]string{}
+	}
+	if strings.TrimSpace(req.Changelog) == "" {
+		delete(news.Changelogs, version)
+	} else {
+		news.Changelogs[version] = req.Changelog
+	}
+	err := saveNews()
+	newsMutex.Unlock()
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to save changelog"})
+		return
+	}
+	log.Printf("[admin] changelog for %s updated", version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "version": version})
+}
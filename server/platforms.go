@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+)
+
+// platformsDir is the top-level directory inside filesDir that holds
+// platform-specific payloads. A file stored at
+// "_platforms/windows-amd64/bin/game.exe" is installed as "bin/game.exe" on
+// windows/amd64 clients only. The architecture part is optional
+// ("_platforms/linux/...").
+const platformsDir = "_platforms"
+
+// platformRule tags every file matching Pattern with an OS and/or
+// architecture. Patterns use path.Match syntax against the slash-separated
+// path relative to filesDir; a pattern ending in "/" matches everything below
+// that directory.
+type platformRule struct {
+	Pattern string `json:"pattern"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+}
+
+type platformRules struct {
+	Rules []platformRule `json:"rules"`
+}
+
+// loadPlatformRules reads the optional platforms.json sidecar. A missing file
+// simply means no rules.
+func loadPlatformRules() ([]platformRule, error) {
+	data, err := os.ReadFile(platformsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules platformRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules.Rules, nil
+}
+
+// matchPattern reports whether the slash-separated path p matches pattern.
+func matchPattern(pattern, p string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(p, pattern)
+	}
+	ok, err := path.Match(pattern, p)
+	return err == nil && ok
+}
+
+// applyPlatform fills in the OS, Arch and Source fields of fileMeta, either
+// from the _platforms directory convention or from the first matching rule.
+func applyPlatform(fileMeta *MetaForFile, rules []platformRule) {
+	if rest, ok := strings.CutPrefix(fileMeta.Path, platformsDir+"/"); ok {
+		platform, installPath, ok := strings.Cut(rest, "/")
+		if ok && platform != "" && installPath != "" {
+			goos, goarch, _ := strings.Cut(platform, "-")
+			fileMeta.Source = fileMeta.Path
+			fileMeta.Path = installPath
+			fileMeta.OS = goos
+			fileMeta.Arch = goarch
+			return
+		}
+	}
+
+	for _, rule := range rules {
+		if matchPattern(rule.Pattern, fileMeta.Path) {
+			fileMeta.OS = rule.OS
+			fileMeta.Arch = rule.Arch
+			return
+		}
+	}
+}
+
+// unscopedFiles returns a copy of files with every remapped entry moved back
+// to its on-disk path, for clients that don't request a platform.
+func unscopedFiles(files []MetaForFile) []MetaForFile {
+	unscoped := make([]MetaForFile, len(files))
+	for i, fileMeta := range files {
+		if fileMeta.Source != "" {
+			fileMeta.Path = fileMeta.Source
+			fileMeta.Source = ""
+		}
+		unscoped[i] = fileMeta
+	}
+	return unscoped
+}
+
+// matchesPlatform reports whether a file should be installed on goos/goarch.
+// Untagged files match every platform, and an empty goos or goarch in the
+// request matches every tag.
+func matchesPlatform(fileMeta MetaForFile, goos, goarch string) bool {
+	if fileMeta.OS != "" && goos != "" && fileMeta.OS != goos {
+		return false
+	}
+	if fileMeta.Arch != "" && goarch != "" && fileMeta.Arch != goarch {
+		return false
+	}
+	return true
+}
+
+// uniquePaths keeps one entry per install path of files, which are in
+// manifest order: a file for the exact os and arch wins over one for the os
+// or arch only, which wins over an untagged file. Overlapping platform
+// overrides would otherwise install two files to one path.
+func uniquePaths(files []MetaForFile) []MetaForFile {
+	unique := files[:0:0]
+	for _, fileMeta := range files {
+		last := len(unique) - 1
+		if last >= 0 && unique[last].Path == fileMeta.Path {
+			if specificity(fileMeta) > specificity(unique[last]) {
+				unique[last] = fileMeta
+			}
+			continue
+		}
+		unique = append(unique, fileMeta)
+	}
+	return unique
+}
+
+// specificity ranks how narrowly a file's tags select a platform.
+func specificity(fileMeta MetaForFile) int {
+	n := 0
+	if fileMeta.OS != "" {
+		n += 2
+	}
+	if fileMeta.Arch != "" {
+		n++
+	}
+	return n
+}
+
+// unknownPlatform stands for an os or arch that no file is tagged with. It
+// matches no tag, so such requests get the untagged files only.
+const unknownPlatform = "?"
+
+// platformTags records which OS and architecture tags the files carry.
+type platformTags struct {
+	os, arch map[string]bool
+}
+
+func collectPlatformTags(files []MetaForFile) platformTags {
+	tags := platformTags{os: map[string]bool{}, arch: map[string]bool{}}
+	for _, fileMeta := range files {
+		if fileMeta.OS != "" {
+			tags.os[fileMeta.OS] = true
+		}
+		if fileMeta.Arch != "" {
+			tags.arch[fileMeta.Arch] = true
+		}
+	}
+	return tags
+}
+
+// normalize maps a requested os and arch to the tags present, so that
+// requests for platforms the release doesn't have share one cache entry.
+func (t platformTags) normalize(goos, goarch string) (string, string) {
+	if goos != "" && !t.os[goos] {
+		goos = unknownPlatform
+	}
+	if goarch != "" && !t.arch[goarch] {
+		goarch = unknownPlatform
+	}
+	return goos, goarch
+}
+
+type platformCacheEntry struct {
+	meta      []byte
+	filesMeta []byte
+	// files and groups back the streamed manifest.
+	files  []MetaForFile
+	groups []GroupMeta
+}
+
+var (
+	// platformCache holds the filtered /meta and /filesmeta responses per
+	// normalized "os/arch" key, so it holds at most one entry per platform
+	// of the release plus the unknown ones. It is reset whenever the meta
+	// files are regenerated and is guarded by cacheMutex.
+	platformCache = map[string]*platformCacheEntry{}
+	// platformTagsCache holds the tags of filesMetaList, guarded by
+	// cacheMutex.
+	platformTagsCache platformTags
+)
+
+// platformMeta returns the /meta and /filesmeta payloads for goos/goarch,
+// building and caching them on first use.
+func platformMeta(goos, goarch string) (*platformCacheEntry, error) {
+	cacheMutex.RLock()
+	goos, goarch = platformTagsCache.normalize(goos, goarch)
+	key := goos + "/" + goarch
+	entry, ok := platformCache[key]
+	files := filesMetaList
+	defs := groupDefsCache
+	generation := metaGeneration
+	cacheMutex.RUnlock()
+	if ok {
+		return entry, nil
+	}
+
+	var filtered []MetaForFile
+	for _, fileMeta := range files {
+		if matchesPlatform(fileMeta, goos, goarch) {
+			filtered = append(filtered, fileMeta)
+		}
+	}
+	filtered = uniquePaths(filtered)
+	var totalSize int64
+	for _, fileMeta := range filtered {
+		totalSize += fileMeta.Size
+	}
+
+	overallHash, err := calculateOverallHash(filtered)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := summarizeGroups(defs, filtered)
+
+	metaJSON, err := json.Marshal(MetaData{Hash: overallHash, TotalSize: totalSize, Groups: groupIDs(groups)})
+	if err != nil {
+		return nil, err
+	}
+
+	filesMetaJSON, err := json.Marshal(MetaDataForFiles{Files: filtered, Groups: groups, Blobs: true})
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &platformCacheEntry{meta: metaJSON, filesMeta: filesMetaJSON, files: filtered, groups: groups}
+
+	// Only cache the result if the manifest wasn't regenerated meanwhile.
+	cacheMutex.Lock()
+	if generation == metaGeneration {
+		platformCache[key] = entry
+	}
+	cacheMutex.Unlock()
+
+	return entry, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ppatcher/semver"
+)
+
+// rolloutHeader carries a patcher's rollout ID: a random ID each install
+// creates only to be placed on one side of staged rollouts.
+const rolloutHeader = "X-Rollout-ID"
+
+// rolloutState is rolloutFile: an archived release served to a share of the
+// patchers before it is promoted.
+type rolloutState struct {
+	Release string `json:"release"`
+	Version string `json:"version"`
+	Percent int    `json:"percent"`
+	// Paused holds the rollout at Percent until it is resumed.
+	Paused  bool      `json:"paused,omitempty"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	// rollout is the rollout in progress, or nil. rolloutMutex is taken
+	// after releasesMutex when both are held.
+	rollout      *rolloutState
+	rolloutMutex sync.RWMutex
+)
+
+func loadRollout() error {
+	data, err := os.ReadFile(rolloutFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var state rolloutState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	rolloutMutex.Lock()
+	rollout = &state
+	rolloutMutex.Unlock()
+	return nil
+}
+
+// setRollout replaces the rollout in progress and tells connected patchers
+// to check for updates. nil ends it. Callers must hold rolloutMutex.
+func setRollout(state *rolloutState) error {
+	if state == nil {
+		if err := os.Remove(rolloutFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := rolloutFile + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, rolloutFile); err != nil {
+			return err
+		}
+	}
+	rollout = state
+	payload := map[string]interface{}{"rollout": nil}
+	if state != nil {
+		payload["rollout"] = state.Version
+		payload["percent"] = state.Percent
+	}
+	events.publish("manifest", payload)
+	return nil
+}
+
+// rolloutReleaseID returns the ID of the release being rolled out, or "".
+func rolloutReleaseID() string {
+	rolloutMutex.RLock()
+	defer rolloutMutex.RUnlock()
+	if rollout == nil {
+		return ""
+	}
+	return rollout.Release
+}
+
+// endRollout ends the rollout of a release that was promoted.
+func endRollout(id string) {
+	rolloutMutex.Lock()
+	defer rolloutMutex.Unlock()
+	if rollout == nil || rollout.Release != id {
+		return
+	}
+	if err := setRollout(nil); err != nil {
+		log.Printf("Failed to end rollout of release %s: %v", id, err)
+		return
+	}
+	log.Printf("Rollout of release %s ended, the release is current", id)
+}
+
+// rolloutBucket places a rollout ID in 0..99. The release is mixed in, so
+// that every rollout starts with different players, while raising the
+// percentage of one only ever adds players.
+func rolloutBucket(release, id string) int {
+	sum := sha256.Sum256([]byte(release + "\x00" + id))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// rolloutRelease returns the release a request gets from the rollout in
+// progress, or nil if it gets the current one. Patchers that send no
+// rollout ID always get the current release.
+func rolloutRelease(r *http.Request) *archivedRelease {
+	id := strings.TrimSpace(r.Header.Get(rolloutHeader))
+	rolloutMutex.RLock()
+	state := rollout
+	rolloutMutex.RUnlock()
+	if state == nil || id == "" || rolloutBucket(state.Release, id) >= state.Percent {
+		return nil
+	}
+	releasesMutex.RLock()
+	defer releasesMutex.RUnlock()
+	return releases[state.Release]
+}
+
+// varyRollout marks a response as depending on the rollout ID while a
+// rollout is in progress, so that caches keep the two sides apart.
+func varyRollout(w http.ResponseWriter) {
+	rolloutMutex.RLock()
+	active := rollout != nil
+	rolloutMutex.RUnlock()
+	if active {
+		w.Header().Add("Vary", rolloutHeader)
+	}
+}
+
+// withRollout serves /meta, /filesmeta, /files/ and /batch to patchers in
+// the rollout from the release being rolled out, the way /versions/ serves
+// archived releases. Other requests go to next.
+func withRollout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varyRollout(w)
+		if release := rolloutRelease(r); release != nil {
+			serveRelease(w, r, release, strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminRolloutHandler manages the staged rollout of an archived release:
+//
+//	GET    /admin/rollout                              the rollout in progress
+//	POST   /admin/rollout {"id": "...", "percent": 5}  start a rollout
+//	POST   /admin/rollout {"percent": 25}              raise it
+//	DELETE /admin/rollout                              abort it
+//
+// Raising a rollout to 100 promotes the release and ends the rollout.
+func adminRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rolloutMutex.RLock()
+		state := rollout
+		rolloutMutex.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"rollout": state})
+	case http.MethodPost:
+		var req struct {
+			ID      string `json:"id"`
+			Percent int    `json:"percent"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Percent < 1 || req.Percent > 100 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "percent must be between 1 and 100"})
+			return
+		}
+		serveRolloutChange(w, req.ID, req.Percent)
+	case http.MethodDelete:
+		rolloutMutex.Lock()
+		state := rollout
+		var err error
+		if state != nil {
+			err = setRollout(nil)
+		}
+		rolloutMutex.Unlock()
+		if state == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "no rollout in progress"})
+			return
+		}
+		if err != nil {
+			log.Printf("[admin] aborting rollout failed: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to abort rollout"})
+			return
+		}
+		log.Printf("[admin] aborted rollout of release %s at %d%%", state.Release, state.Percent)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// serveRolloutChange starts or raises a rollout, and promotes the release
+// once it reaches 100%. Only releases newer than the current version can be
+// rolled out; going back is a rollback.
+func serveRolloutChange(w http.ResponseWriter, id string, percent int) {
+	cacheMutex.RLock()
+	currentVersion := versionCache
+	cacheMutex.RUnlock()
+
+	releasesMutex.RLock()
+	rolloutMutex.Lock()
+	state := rollout
+	if id == "" && state != nil {
+		id = state.Release
+	}
+	release, ok := releases[id]
+	isCurrent := id == current.Current
+	newer := false
+	if ok {
+		c, comparable := semver.Compare(release.Version, currentVersion)
+		newer = comparable && c > 0
+	}
+
+	var problem string
+	status := http.StatusBadRequest
+	switch {
+	case !ok:
+		problem, status = "release not found", http.StatusNotFound
+	case isCurrent:
+		problem = "the release is already current"
+	case !newer:
+		problem = fmt.Sprintf("version %s isn't newer than the current version %s; use a rollback to go back", release.Version, currentVersion)
+	case state != nil && state.Release != id:
+		problem, status = "another release is being rolled out", http.StatusConflict
+	case state != nil && state.Paused:
+		problem, status = "the rollout is paused", http.StatusConflict
+	case state != nil && percent < state.Percent:
+		problem = "a rollout can't be lowered; abort it instead"
+	}
+	if problem != "" {
+		rolloutMutex.Unlock()
+		releasesMutex.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": problem})
+		return
+	}
+
+	if percent == 100 {
+		rolloutMutex.Unlock()
+		releasesMutex.RUnlock()
+		// promoteRelease ends the rollout.
+		servePromotion(w, id, false, nil, false)
+		return
+	}
+
+	now := time.Now().UTC()
+	next := &rolloutState{Release: id, Version: release.Version, Percent: percent, Started: now, Updated: now}
+	if state != nil {
+		next.Started = state.Started
+	}
+	err := setRollout(next)
+	rolloutMutex.Unlock()
+	releasesMutex.RUnlock()
+	if err != nil {
+		log.Printf("[admin] updating rollout failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to update rollout"})
+		return
+	}
+	log.Printf("[admin] rolling out release %s (version %s) to %d%%", id, release.Version, percent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "rollout": next})
+}
+
+// adminRolloutPauseHandler pauses or resumes the rollout in progress:
+// POST /admin/rollout/pause {"paused": true}. A paused rollout keeps serving
+// the release to the players it includes but can't be raised.
+func adminRolloutPauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	req := struct {
+		Paused bool `json:"paused"`
+	}{Paused: true}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request"})
+		return
+	}
+
+	rolloutMutex.Lock()
+	defer rolloutMutex.Unlock()
+	if rollout == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no rollout in progress"})
+		return
+	}
+	next := *rollout
+	next.Paused = req.Paused
+	next.Updated = time.Now().UTC()
+	if err := setRollout(&next); err != nil {
+		log.Printf("[admin] updating rollout failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to update rollout"})
+		return
+	}
+	log.Printf("[admin] rollout of release %s paused: %v", next.Release, next.Paused)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "rollout": next})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ppatcher/semver"
+)
+
+// telemetryRecord is a line of telemetry.jsonl: an update report as a
+// patcher sent it, with the time it arrived.
+type telemetryRecord struct {
+	Received       time.Time `json:"received"`
+	InstallID      string    `json:"installId"`
+	Product        string    `json:"product"`
+	PatcherVersion string    `json:"patcherVersion"`
+	Version        string    `json:"version"`
+	OS             string    `json:"os"`
+	Arch           string    `json:"arch"`
+	DurationMs     int64     `json:"durationMs"`
+	Bytes          int64     `json:"bytes"`
+	Files          int       `json:"files"`
+	Downloaded     int       `json:"downloaded"`
+	Retried        int       `json:"retried"`
+	Failed         int       `json:"failed"`
+	Status         string    `json:"status"`
+}
+
+// versionStats aggregates the reports of one release version.
+type versionStats struct {
+	Version string `json:"version"`
+	Reports int    `json:"reports"`
+	// Installs counts distinct install IDs.
+	Installs int `json:"installs"`
+	Success  int `json:"success"`
+	Partial  int `json:"partial"`
+	Failed   int `json:"failed"`
+	// SuccessRate is Success divided by Reports.
+	SuccessRate      float64 `json:"successRate"`
+	MedianDurationMs int64   `json:"medianDurationMs"`
+	Bytes            int64   `json:"bytes"`
+	Retried          int     `json:"retried"`
+
+	installs  map[string]bool
+	durations []int64
+}
+
+var (
+	// telemetryMutex serialises appends to telemetry.jsonl.
+	telemetryMutex   sync.Mutex
+	telemetryLimiter = newRateLimiter(30, time.Minute)
+	installIDPattern = regexp.MustCompile(`^[0-9a-f]{8,64}$`)
+	telemetryStates  = map[string]bool{"success": true, "partial": true, "failed": true}
+)
+
+// telemetryHandler appends an update report to telemetry.jsonl. Reports are
+// only sent by patchers whose players opted in.
+func telemetryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	ip := r.RemoteAddr
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		ip = strings.SplitN(fwd, ",", 2)[0]
+	}
+	if !telemetryLimiter.allow(strings.TrimSpace(ip)) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": "too many requests, try again later"})
+		return
+	}
+
+	var record telemetryRecord
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&record); err != nil || !validTelemetry(record) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid report"})
+		return
+	}
+	record.Received = time.Now().UTC()
+
+	if err := appendTelemetry(record); err != nil {
+		log.Printf("Failed to store telemetry: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to store report"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"ok": "true"})
+}
+
+// validTelemetry rejects reports that no patcher would send, so that the
+// store only grows by short records.
+func validTelemetry(record telemetryRecord) bool {
+	if !installIDPattern.MatchString(record.InstallID) || !telemetryStates[record.Status] {
+		return false
+	}
+	for _, s := range []string{record.Product, record.PatcherVersion, record.Version, record.OS, record.Arch} {
+		if utf8.RuneCountInString(s) > 64 {
+			return false
+		}
+	}
+	return record.DurationMs >= 0 && record.Bytes >= 0 && record.Files >= 0 &&
+		record.Downloaded >= 0 && record.Retried >= 0 && record.Failed >= 0
+}
+
+func appendTelemetry(record telemetryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	telemetryMutex.Lock()
+	defer telemetryMutex.Unlock()
+	f, err := os.OpenFile(telemetryFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// adminStatsHandler aggregates telemetry.jsonl per release version, newest
+// first. ?product= limits the stats to one product and ?since= (RFC 3339)
+// to recent reports.
+func adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	product := r.URL.Query().Get("product")
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "since must be an RFC 3339 time"})
+			return
+		}
+	}
+
+	stats, err := telemetryStats(product, since)
+	if err != nil {
+		log.Printf("[admin] reading telemetry failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read telemetry"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"versions": stats})
+}
+
+// telemetryStats reads the whole store. Lines that can't be parsed, such as
+// one cut short by a crash, are skipped.
+func telemetryStats(product string, since time.Time) ([]*versionStats, error) {
+	f, err := os.Open(telemetryFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*versionStats{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	byVersion := map[string]*versionStats{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024)
+	for scanner.Scan() {
+		var record telemetryRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if (product != "" && record.Product != product) || record.Received.Before(since) {
+			continue
+		}
+		s := byVersion[record.Version]
+		if s == nil {
+			s = &versionStats{Version: record.Version, installs: map[string]bool{}}
+			byVersion[record.Version] = s
+		}
+		s.Reports++
+		s.installs[record.InstallID] = true
+		s.durations = append(s.durations, record.DurationMs)
+		s.Bytes += record.Bytes
+		s.Retried += record.Retried
+		switch record.Status {
+		case "success":
+			s.Success++
+		case "partial":
+			s.Partial++
+		default:
+			s.Failed++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]*versionStats, 0, len(byVersion))
+	for _, s := range byVersion {
+		s.Installs = len(s.installs)
+		s.SuccessRate = float64(s.Success) / float64(s.Reports)
+		sort.Slice(s.durations, func(i, j int) bool { return s.durations[i] < s.durations[j] })
+		if n := len(s.durations); n%2 == 1 {
+			s.MedianDurationMs = s.durations[n/2]
+		} else {
+			s.MedianDurationMs = (s.durations[n/2-1] + s.durations[n/2]) / 2
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if c, ok := semver.Compare(stats[i].Version, stats[j].Version); ok && c != 0 {
+			return c > 0
+		}
+		return stats[i].Version > stats[j].Version
+	})
+	return stats, nil
+}
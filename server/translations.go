@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+)
+
+// translationsHandler serves the optional translations.json sidecar: message
+// overrides for the patcher UI by language code and message key, such as
+// {"de": {"update.check": "Aktualisieren"}}. The file is read on every
+// request so it can be edited without a restart. Without it the endpoint
+// answers 404 and patchers keep their built-in messages.
+func translationsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := os.ReadFile(translationsFile)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var translations map[string]map[string]string
+	if err == nil {
+		err = json.Unmarshal(data, &translations)
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", translationsFile, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "translations unavailable"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translations)
+}
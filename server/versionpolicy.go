@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// versionPolicy is the persisted form of versionpolicy.json: which releases
+// patchers may still launch.
+type versionPolicy struct {
+	// MinimumVersion is the oldest release patchers may launch. Older
+	// installs must update first.
+	MinimumVersion string `json:"minimumVersion,omitempty"`
+	// Mandatory means the current release must be installed before
+	// launching. It applies to the release it was set with.
+	Mandatory bool `json:"mandatory,omitempty"`
+}
+
+// policyCache is guarded by cacheMutex, like versionCache.
+var policyCache versionPolicy
+
+func loadVersionPolicy() error {
+	data, err := os.ReadFile(versionPolicyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &policyCache)
+}
+
+func saveVersionPolicy(policy versionPolicy) error {
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := versionPolicyFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, versionPolicyFile)
+}
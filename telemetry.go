@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	goRunTime "runtime"
+)
+
+// TelemetryConfig configures the anonymous update reports players can opt
+// in to.
+type TelemetryConfig struct {
+	// Enabled offers players to send a report to the backend after every
+	// update. Nothing is sent until a player opts in.
+	Enabled bool `json:"enabled"`
+}
+
+// TelemetryInfo tells the frontend whether update reports can be sent and
+// whether the player opted in.
+type TelemetryInfo struct {
+	Available bool `json:"available"`
+	Enabled   bool `json:"enabled"`
+}
+
+// Final states of a telemetryReport.
+const (
+	telemetrySuccess = "success"
+	// telemetryPartial: the update finished, but some files failed.
+	telemetryPartial = "partial"
+	telemetryFailed  = "failed"
+)
+
+// telemetryReport is what the backend receives after an update attempt. It
+// carries no file names, paths or error messages.
+type telemetryReport struct {
+	// InstallID is a random ID created when the player opts in, so that
+	// reports of one install can be counted once.
+	InstallID      string `json:"installId"`
+	Product        string `json:"product"`
+	PatcherVersion string `json:"patcherVersion"`
+	// Version is the release that was being installed.
+	Version    string `json:"version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	DurationMs int64  `json:"durationMs"`
+	Bytes      int64  `json:"bytes"`
+	Files      int    `json:"files"`
+	Downloaded int    `json:"downloaded"`
+	Retried    int    `json:"retried"`
+	Failed     int    `json:"failed"`
+	Status     string `json:"status"`
+}
+
+// Telemetry returns whether update reports are offered and sent.
+func (a *App) Telemetry() TelemetryInfo {
+	return TelemetryInfo{
+		Available: BuildConfig.Telemetry.Enabled,
+		Enabled:   BuildConfig.Telemetry.Enabled && loadSettings().Telemetry,
+	}
+}
+
+// SetTelemetry records whether the player wants to send update reports.
+// Opting out forgets the install ID, so a later opt-in starts afresh.
+func (a *App) SetTelemetry(enabled bool) error {
+	s := loadSettings()
+	s.Telemetry = enabled
+	if !enabled {
+		s.InstallID = ""
+	} else if s.InstallID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		s.InstallID = hex.EncodeToString(id)
+	}
+	if err := saveSettings(s); err != nil {
+		return err
+	}
+	logger.Info("update reports changed", "enabled", enabled)
+	return nil
+}
+
+// sendTelemetry posts a report of an update attempt to {backend}/telemetry
+// in the background if the player opted in. report is nil when the update
+// failed before any file was checked. Failures to send are only logged.
+func (p *product) sendTelemetry(report *UpdateReport, started time.Time, err error) {
+	s := loadSettings()
+	if !BuildConfig.Telemetry.Enabled || !s.Telemetry || s.InstallID == "" {
+		return
+	}
+
+	payload := telemetryReport{
+		InstallID:      s.InstallID,
+		Product:        p.config.ID,
+		PatcherVersion: p.config.Version,
+		Version:        p.version(),
+		OS:             goRunTime.GOOS,
+		Arch:           goRunTime.GOARCH,
+		DurationMs:     time.Since(started).Milliseconds(),
+		Status:         telemetrySuccess,
+	}
+	if pinned := p.pinnedVersion(); pinned != "" {
+		payload.Version = pinned
+	}
+	if report != nil {
+		report.mu.Lock()
+		payload.Bytes = report.Bytes
+		payload.Files = report.Checked
+		payload.Downloaded = report.Downloaded
+		payload.Retried = report.Retried
+		payload.Failed = len(report.Failed)
+		report.mu.Unlock()
+	}
+	switch {
+	case err != nil:
+		payload.Status = telemetryFailed
+	case payload.Failed > 0:
+		payload.Status = telemetryPartial
+	}
+
+	body, jsonErr := json.Marshal(payload)
+	if jsonErr != nil {
+		return
+	}
+	go func() {
+		resp, err := p.request(http.MethodPost, "/telemetry", func() io.Reader {
+			return bytes.NewReader(body)
+		}, 0)
+		if err != nil {
+			logger.Debug("sending update report failed", "product", p.config.ID, "err", err)
+			return
+		}
+		resp.Body.Close()
+		logger.Debug("update report sent", "product", p.config.ID, "status", payload.Status)
+	}()
+}
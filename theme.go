@@ -0,0 +1,182 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Theme is the look of the launcher. Config.Theme overrides single values of
+// the palette chosen with colorPalette; Config() always serves the complete
+// theme. Colours are hex values such as "#3182ce".
+type Theme struct {
+	Primary        string `json:"primary"`
+	PrimaryHover   string `json:"primaryHover"`
+	Secondary      string `json:"secondary"`
+	SecondaryHover string `json:"secondaryHover"`
+	// BackgroundGradient lists the colours of the window background from
+	// top left to bottom right. A single colour is a plain background.
+	BackgroundGradient []string `json:"backgroundGradient"`
+	TextPrimary        string   `json:"textPrimary"`
+	TextSecondary      string   `json:"textSecondary"`
+	Success            string   `json:"success"`
+	Error              string   `json:"error"`
+	Info               string   `json:"info"`
+	CardBg             string   `json:"cardBg"`
+	ProgressBg         string   `json:"progressBg"`
+	Disabled           string   `json:"disabled"`
+	DisabledText       string   `json:"disabledText"`
+	// BackgroundImage is an https or data URL drawn over the gradient.
+	BackgroundImage string `json:"backgroundImage"`
+	// Font is a CSS font family name. FontURL optionally loads it from a
+	// font file.
+	Font    string `json:"font"`
+	FontURL string `json:"fontUrl"`
+}
+
+// builtinThemes are the palettes colorPalette chooses from.
+var builtinThemes = map[string]Theme{
+	"neutral": {
+		Primary: "#6b7280", PrimaryHover: "#4b5563", Secondary: "#9ca3af", SecondaryHover: "#6b7280",
+		BackgroundGradient: []string{"#f9fafb", "#f3f4f6"},
+		TextPrimary:        "#374151", TextSecondary: "#6b7280",
+		Success: "#059669", Error: "#dc2626", Info: "#2563eb",
+		CardBg: "#ffffff", ProgressBg: "#e5e7eb", Disabled: "#d1d5db", DisabledText: "#9ca3af",
+	},
+	"blue": {
+		Primary: "#3182ce", PrimaryHover: "#2b6cb0", Secondary: "#63b3ed", SecondaryHover: "#4299e1",
+		BackgroundGradient: []string{"#ebf8ff", "#bee3f8"},
+		TextPrimary:        "#2a4365", TextSecondary: "#4c51bf",
+		Success: "#38a169", Error: "#e53e3e", Info: "#3182ce",
+		CardBg: "#ffffff", ProgressBg: "#e6fffa", Disabled: "#93c5fd", DisabledText: "#93c5fd",
+	},
+	"green": {
+		Primary: "#38a169", PrimaryHover: "#2f855a", Secondary: "#68d391", SecondaryHover: "#48bb78",
+		BackgroundGradient: []string{"#f0fff4", "#c6f6d5"},
+		TextPrimary:        "#22543d", TextSecondary: "#38a169",
+		Success: "#38a169", Error: "#e53e3e", Info: "#3182ce",
+		CardBg: "#ffffff", ProgressBg: "#e6fffa", Disabled: "#9ae6b4", DisabledText: "#9ae6b4",
+	},
+	"purple": {
+		Primary: "#805ad5", PrimaryHover: "#6b46c1", Secondary: "#9f7aea", SecondaryHover: "#805ad5",
+		BackgroundGradient: []string{"#faf5ff", "#e9d8fd"},
+		TextPrimary:        "#322659", TextSecondary: "#553c9a",
+		Success: "#38a169", Error: "#e53e3e", Info: "#3182ce",
+		CardBg: "#ffffff", ProgressBg: "#e6fffa", Disabled: "#d6bcfa", DisabledText: "#d6bcfa",
+	},
+}
+
+var (
+	hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	fontName = regexp.MustCompile(`^[\pL\pN _-]+$`)
+)
+
+// resolveTheme fills config.Theme from the palette and checks it. Invalid
+// colours keep the palette's value; colours that are hard to read together
+// only produce warnings.
+func (l *configLoader) resolveTheme(config *Config) {
+	base := builtinThemes[config.ColorPalette]
+	theme := config.Theme
+
+	// Colours are checked field by field, so that a single typo doesn't
+	// discard the whole theme.
+	value := reflect.ValueOf(&theme).Elem()
+	baseValue := reflect.ValueOf(base)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		key := value.Type().Field(i).Tag.Get("json")
+		if field.Kind() != reflect.String || key == "backgroundImage" || key == "font" || key == "fontUrl" {
+			continue
+		}
+		if field.String() == "" {
+			field.SetString(baseValue.Field(i).String())
+		} else if !hexColor.MatchString(field.String()) {
+			l.errorf("theme.%s (%s): %q is not a hex colour such as \"#3182ce\"", key, l.report.Sources["theme."+key], field.String())
+			field.SetString(baseValue.Field(i).String())
+		}
+	}
+
+	if len(theme.BackgroundGradient) == 0 {
+		theme.BackgroundGradient = base.BackgroundGradient
+	}
+	for _, color := range theme.BackgroundGradient {
+		if !hexColor.MatchString(color) {
+			l.errorf("theme.backgroundGradient (%s): %q is not a hex colour such as \"#3182ce\"", l.report.Sources["theme.backgroundGradient"], color)
+			theme.BackgroundGradient = base.BackgroundGradient
+			break
+		}
+	}
+
+	if theme.BackgroundImage != "" && !safeAssetURL(theme.BackgroundImage) {
+		l.errorf("theme.backgroundImage (%s): must be an https or data URL", l.report.Sources["theme.backgroundImage"])
+		theme.BackgroundImage = ""
+	}
+	if theme.Font != "" && !fontName.MatchString(theme.Font) {
+		l.errorf("theme.font (%s): %q is not a font family name", l.report.Sources["theme.font"], theme.Font)
+		theme.Font = ""
+	}
+	if theme.FontURL != "" && (theme.Font == "" || !safeAssetURL(theme.FontURL)) {
+		l.errorf("theme.fontUrl (%s): needs theme.font and an https or data URL", l.report.Sources["theme.fontUrl"])
+		theme.FontURL = ""
+	}
+
+	checkContrast := func(what, fg, bg string, minimum float64) {
+		if ratio := contrastRatio(fg, bg); ratio < minimum {
+			l.warnf("theme: %s has a contrast ratio of %.1f:1, below the recommended %.1f:1", what, ratio, minimum)
+		}
+	}
+	checkContrast("textPrimary on cardBg", theme.TextPrimary, theme.CardBg, 4.5)
+	checkContrast("textSecondary on cardBg", theme.TextSecondary, theme.CardBg, 3)
+	checkContrast("button text on primary", "#ffffff", theme.Primary, 3)
+	checkContrast("error on cardBg", theme.Error, theme.CardBg, 3)
+	for _, color := range theme.BackgroundGradient {
+		checkContrast("textPrimary on backgroundGradient", theme.TextPrimary, color, 3)
+	}
+
+	config.Theme = theme
+}
+
+// safeAssetURL reports whether u is an https or data URL that can be used
+// in CSS as is.
+func safeAssetURL(u string) bool {
+	if strings.ContainsAny(u, "\"'()\\ \t\r\n") {
+		return false
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "https" && parsed.Host != "") || parsed.Scheme == "data"
+}
+
+// contrastRatio returns the WCAG contrast ratio of two hex colours, ignoring
+// transparency.
+func contrastRatio(a, b string) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func luminance(color string) float64 {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) < 6 {
+		return 0
+	}
+	channel := func(s string) float64 {
+		v, _ := strconv.ParseUint(s, 16, 8)
+		c := float64(v) / 255
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(hex[0:2]) + 0.7152*channel(hex[2:4]) + 0.0722*channel(hex[4:6])
+}
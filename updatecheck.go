@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults for Config.UpdateCheck.
+const (
+	defaultCheckInterval = 30 * time.Minute
+	defaultMaxBackoff    = 4 * time.Hour
+	// checkJitter spreads checks of many launchers by up to ±10%.
+	checkJitter = 0.1
+)
+
+// Update policies for background checks.
+const (
+	updatePolicyPrompt = "prompt"
+	updatePolicyAuto   = "auto"
+)
+
+// UpdateCheckConfig controls the checks the patcher runs while it stays
+// open. Durations use Go syntax such as "30m" or "2h".
+type UpdateCheckConfig struct {
+	// Interval between checks. "0" disables background checks.
+	Interval string `json:"interval"`
+	// MaxBackoff caps the delay between checks while the backend is
+	// unreachable. The delay doubles with every failed check.
+	MaxBackoff string `json:"maxBackoff"`
+	// Policy is "prompt" to notify the player, or "auto" to download
+	// updates right away.
+	Policy string `json:"policy"`
+	// DisableEvents turns off the /events subscription that triggers a
+	// check as soon as the backend publishes new files.
+	DisableEvents bool `json:"disableEvents"`
+}
+
+// UpdateAvailable is the payload of the "updateAvailable" event.
+type UpdateAvailable struct {
+	Version   string `json:"version"`
+	Hash      string `json:"hash"`
+	TotalSize int64  `json:"totalSize"`
+	// Download is false when only the version changed and no files need
+	// to be downloaded.
+	Download bool `json:"download"`
+	// Required is set when the update must be installed before the
+	// product can be launched again.
+	Required bool   `json:"required"`
+	Policy   string `json:"policy"`
+}
+
+// updateCheckSettings returns the parsed background check settings. Invalid
+// durations fall back to the defaults.
+func updateCheckSettings() (interval, maxBackoff time.Duration, policy string) {
+	config := BuildConfig.UpdateCheck
+	interval, maxBackoff = defaultCheckInterval, defaultMaxBackoff
+	if config.Interval != "" {
+		if d, err := time.ParseDuration(config.Interval); err == nil && d >= 0 {
+			interval = d
+		} else {
+			logger.Warn("invalid update check interval, using default", "interval", config.Interval)
+		}
+	}
+	if config.MaxBackoff != "" {
+		if d, err := time.ParseDuration(config.MaxBackoff); err == nil && d > 0 {
+			maxBackoff = d
+		} else {
+			logger.Warn("invalid update check backoff, using default", "maxBackoff", config.MaxBackoff)
+		}
+	}
+	if maxBackoff < interval {
+		maxBackoff = interval
+	}
+	policy = updatePolicyPrompt
+	if config.Policy == updatePolicyAuto {
+		policy = updatePolicyAuto
+	}
+	return interval, maxBackoff, policy
+}
+
+// checkDelay returns the jittered delay before the next check after the
+// given number of consecutive failures.
+func checkDelay(interval, maxBackoff time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	jitter := (rand.Float64()*2 - 1) * checkJitter
+	return delay + time.Duration(float64(delay)*jitter)
+}
+
+// watchUpdates checks the product for updates until ctx is done.
+func (p *product) watchUpdates(ctx context.Context) {
+	interval, maxBackoff, policy := updateCheckSettings()
+	if interval == 0 {
+		return
+	}
+
+	failures := 0
+	for {
+		timer := time.NewTimer(checkDelay(interval, maxBackoff, failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := p.backgroundCheck(policy); err != nil {
+			failures++
+			logger.Warn("background update check failed", "product", p.config.ID, "failures", failures, "err", err)
+		} else {
+			failures = 0
+		}
+	}
+}
+
+// backgroundCheck compares the installed files and version with the backend
+// and emits "updateAvailable" for anything new. With the auto policy it
+// downloads the update right away. It does nothing while another check or
+// update is running, and for installs pinned to a version.
+func (p *product) backgroundCheck(policy string) error {
+	if p.rootDir() == "" || p.pinnedVersion() != "" {
+		return nil
+	}
+	if !p.updating.TryLock() {
+		return nil
+	}
+	defer p.updating.Unlock()
+
+	version := p.version()
+	p.fetchRemoteVersion()
+	versionChanged := p.version() != version
+
+	rel := &release{p: p}
+	should, err := p.shouldUpdate(rel)
+	if errors.Is(err, errLicenseRequired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p.fetchNews()
+
+	meta := p.remoteMeta()
+	if (!should || meta.Hash == p.notifiedHash) && !versionChanged {
+		return nil
+	}
+	if should {
+		p.notifiedHash = meta.Hash
+	}
+
+	logger.Info("update available", "product", p.config.ID, "version", p.version(), "hash", meta.Hash, "download", should)
+	p.emit("updateAvailable", UpdateAvailable{
+		Version:   p.version(),
+		Hash:      meta.Hash,
+		TotalSize: meta.TotalSize,
+		Download:  should,
+		Required:  p.versionStatus().State == versionRequired,
+		Policy:    policy,
+	})
+
+	if !should {
+		return nil
+	}
+	if policy == updatePolicyAuto {
+		p.setStatus("downloading")
+		return p.update(rel)
+	}
+	p.setStatus("updateAvailable")
+	return nil
+}
@@ -0,0 +1,141 @@
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	// maxBatchFiles and maxBatchBytes bound a single /batch request. The
+	// server refuses more than 1000 paths.
+	maxBatchFiles = 500
+	maxBatchBytes = 8 << 20
+)
+
+// errBatchUnsupported is returned by downloadBatch when the backend has no
+// /batch endpoint.
+var errBatchUnsupported = errors.New("batch downloads not supported")
+
+// downloadBatch collects small files during an update until there are
+// enough for one /batch request.
+type downloadBatch struct {
+	threshold int64
+
+	mu       sync.Mutex
+	files    []File
+	size     int64
+	disabled bool
+}
+
+func (c *Client) newDownloadBatch() *downloadBatch {
+	threshold := c.BatchThreshold
+	if threshold == 0 {
+		threshold = DefaultBatchThreshold
+	}
+	return &downloadBatch{threshold: threshold}
+}
+
+// add queues file if it is small enough to be batched. When the queued
+// files fill a request, they are removed and returned as full.
+func (b *downloadBatch) add(file File) (queued bool, full []File) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.disabled || file.Size >= b.threshold {
+		return false, nil
+	}
+	b.files = append(b.files, file)
+	b.size += file.Size
+	if len(b.files) < maxBatchFiles && b.size < maxBatchBytes {
+		return true, nil
+	}
+	full = b.files
+	b.files, b.size = nil, 0
+	return true, full
+}
+
+// take removes and returns the queued files.
+func (b *downloadBatch) take() []File {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	files := b.files
+	b.files, b.size = nil, 0
+	return files
+}
+
+// disable stops batching for the rest of the update.
+func (b *downloadBatch) disable() {
+	b.mu.Lock()
+	b.disabled = true
+	b.mu.Unlock()
+}
+
+// downloadBatch fetches files with a single request to {backend}/batch,
+// which answers with a tar archive, and installs each entry as it arrives,
+// verified against the manifest like a single download. It returns the
+// files that were installed; the others have to be downloaded on their own.
+func (c *Client) downloadBatch(ctx context.Context, files []File, pool *downloadPool) (map[string]bool, error) {
+	c.log().Debug("downloading batch", "files", len(files))
+
+	bySource := make(map[string]File, len(files))
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		bySource[file.source()] = file
+		paths = append(paths, file.source())
+	}
+	body, err := json.Marshal(map[string][]string{"paths": paths})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Request(ctx, http.MethodPost, c.releasePath("/batch"), func() io.Reader {
+		return bytes.NewReader(body)
+	}, c.idleTimeout())
+	if err != nil {
+		pool.failed()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, readAuthError(resp)
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil, errBatchUnsupported
+	default:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		pool.failed()
+		return nil, &StatusError{resp.StatusCode}
+	}
+
+	installed := make(map[string]bool, len(files))
+	archive := tar.NewReader(pool.count(resp.Body))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return installed, nil
+		}
+		if err != nil {
+			pool.failed()
+			return installed, err
+		}
+		file, ok := bySource[header.Name]
+		if !ok || installed[file.Path] {
+			continue
+		}
+		// A corrupt entry leaves the file to be downloaded again on its
+		// own; the rest of the archive is still usable.
+		if err := c.Install(file, "", archive); err != nil {
+			c.log().Warn("installing batched file failed", "path", file.Path, "err", err)
+			continue
+		}
+		installed[file.Path] = true
+	}
+}
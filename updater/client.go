@@ -0,0 +1,274 @@
+// Package updater keeps an install directory in sync with a ppatcher
+// backend. It is what the patcher runs under its UI and in headless mode,
+// and can be used by other Go programs and tests against any backend that
+// speaks the server's protocol.
+//
+// An update goes through these steps, each available on its own:
+//
+//	Manifest  fetch the list of files of the release
+//	Diff      compare the installed files with it
+//	Download  fetch and install the files that differ, verified on the way
+//	Cleanup   remove files the release no longer has
+//	Launch    start the installed executable
+//
+// Check combines Manifest and Diff, Update runs them all but Launch, and
+// Verify re-hashes installed files. Check and Update read the manifest with
+// OpenManifest, one file at a time, so large releases aren't held in
+// memory: their plans list the changes only, and callers that need every
+// file of the release read it with OpenManifest as well. Progress is reported to the client's EventSink.
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+)
+
+// Client talks to one product's backend and installs its files below Root.
+// The zero value of every optional field is a usable default. A Client must
+// not be modified while one of its methods runs.
+type Client struct {
+	// Backends are the backend base URLs, tried in order until one answers.
+	Backends []string
+	// Root is the install directory every manifest path is resolved
+	// against.
+	Root string
+	// Token is sent as a bearer token when set.
+	Token string
+	// RolloutID is sent with every request so the backend can place the
+	// install on one side of a staged rollout. It should stay the same
+	// across runs.
+	RolloutID string
+	// Release is an archived version to install instead of the current
+	// release.
+	Release string
+	// Blobs downloads payloads by their hash from /blobs/ rather than by
+	// path from /files/. Those URLs are the same for every release, so
+	// caches in front of the backend serve a file once. Set it from
+	// Manifest.Blobs; Update does.
+	Blobs bool
+	// OS and Arch select the platform's files. They default to the
+	// platform the program runs on.
+	OS, Arch string
+
+	// HTTPClient is used for API requests and DownloadClient for file
+	// payloads, which are only bounded by IdleTimeout. Both default to
+	// http.DefaultClient.
+	HTTPClient     *http.Client
+	DownloadClient *http.Client
+	// IdleTimeout is how long a download may stall before it fails.
+	// Zero uses 30 seconds.
+	IdleTimeout time.Duration
+
+	// MinDownloads and MaxDownloads bound the number of files downloaded
+	// at once; Download adapts between them to the measured throughput.
+	// Zero uses 2 and 16.
+	MinDownloads, MaxDownloads int
+	// BatchThreshold is the size below which files are downloaded together
+	// through /batch. Zero uses 64 KiB, a negative value turns batching
+	// off.
+	BatchThreshold int64
+	// Last lists paths that are only downloaded once every other file is
+	// installed, such as the executables, so that a half-updated install
+	// can't be started.
+	Last []string
+
+	// Sink receives the progress of Diff, Download and Cleanup.
+	Sink EventSink
+	// Logger receives debug and failure details. Nothing is logged when
+	// it is nil.
+	Logger *slog.Logger
+}
+
+// Defaults for the optional fields of Client.
+const (
+	DefaultIdleTimeout    = 30 * time.Second
+	DefaultMinDownloads   = 2
+	DefaultMaxDownloads   = 16
+	DefaultBatchThreshold = 64 << 10
+)
+
+// ErrLicenseRequired matches every AuthError.
+var ErrLicenseRequired = errors.New("license required")
+
+// AuthError is returned when the backend refuses a request for lack of a
+// valid license token. Message is the server's reason.
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrLicenseRequired
+}
+
+// readAuthError builds an AuthError from a 401 or 403 response.
+func readAuthError(resp *http.Response) error {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	json.Unmarshal(body, &payload)
+	if payload.Error == "" {
+		payload.Error = ErrLicenseRequired.Error()
+	}
+	return &AuthError{Status: resp.StatusCode, Message: payload.Error}
+}
+
+// StatusError is returned for unexpected response codes.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.Code)
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return c.Logger
+}
+
+func (c *Client) emit(event Event) {
+	if c.Sink != nil {
+		c.Sink.Event(event)
+	}
+}
+
+func (c *Client) platform() (goos, goarch string) {
+	goos, goarch = c.OS, c.Arch
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	return goos, goarch
+}
+
+// platformQuery scopes manifest requests to the client's platform, so the
+// server only lists the files meant for it.
+func (c *Client) platformQuery() string {
+	goos, goarch := c.platform()
+	return "?os=" + url.QueryEscape(goos) + "&arch=" + url.QueryEscape(goarch)
+}
+
+// releasePath returns the backend path of a manifest or payload: the
+// current release's, or the archived one's when Release is set.
+func (c *Client) releasePath(path string) string {
+	if c.Release == "" {
+		return path
+	}
+	return "/versions/" + url.PathEscape(c.Release) + path
+}
+
+// Get requests path from the first backend that answers.
+func (c *Client) Get(ctx context.Context, path string) (*http.Response, error) {
+	return c.Request(ctx, http.MethodGet, path, nil, 0)
+}
+
+// Request sends a request to the first backend that answers, moving on to
+// the next one when a backend is unreachable or answers with a server
+// error. body, if set, returns a fresh JSON request body for every attempt.
+//
+// With an idleTimeout the request uses DownloadClient and fails once the
+// response stalls for that long; otherwise it uses HTTPClient. The token is
+// sent when the client has one, and 401 and 403 responses to GET requests
+// are returned as an AuthError without trying other backends.
+func (c *Client) Request(ctx context.Context, method, path string, body func() io.Reader, idleTimeout time.Duration) (*http.Response, error) {
+	client := c.HTTPClient
+	if idleTimeout > 0 {
+		client = c.DownloadClient
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	lastErr := errors.New("no backend configured")
+	for _, backend := range c.Backends {
+		ctx, cancel := context.WithCancel(ctx)
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = body()
+		}
+		req, err := http.NewRequestWithContext(ctx, method, backend+path, reqBody)
+		if err != nil {
+			cancel()
+			lastErr = err
+			continue
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+		if c.RolloutID != "" {
+			req.Header.Set("X-Rollout-ID", c.RolloutID)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			cancel()
+			c.log().Warn("backend request failed", "backend", backend, "path", path, "err", err)
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			resp.Body.Close()
+			cancel()
+			c.log().Warn("backend request failed", "backend", backend, "path", path, "status", resp.StatusCode)
+			lastErr = &StatusError{resp.StatusCode}
+			continue
+		}
+		if method == http.MethodGet && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			err := readAuthError(resp)
+			resp.Body.Close()
+			cancel()
+			return nil, err
+		}
+		if idleTimeout > 0 {
+			resp.Body = newIdleTimeoutBody(resp.Body, idleTimeout, cancel)
+		} else {
+			resp.Body = cancelOnClose{resp.Body, cancel}
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// getFile is like Get for file payloads.
+func (c *Client) getFile(ctx context.Context, path string) (*http.Response, error) {
+	return c.Request(ctx, http.MethodGet, path, nil, c.idleTimeout())
+}
+
+func (c *Client) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return DefaultIdleTimeout
+}
+
+// cancelOnClose releases a request's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,41 @@
+package updater
+
+// EventKind tells what an Event reports.
+type EventKind string
+
+const (
+	// EventUpToDate: Diff found File installed with the expected contents.
+	EventUpToDate EventKind = "upToDate"
+	// EventDownloading: Download started on File.
+	EventDownloading EventKind = "downloading"
+	// EventRetrying: File failed with Err and is tried again as Attempt.
+	EventRetrying EventKind = "retrying"
+	// EventDownloaded: File was downloaded, verified and installed.
+	EventDownloaded EventKind = "downloaded"
+	// EventFailed: File couldn't be installed; Err is the last reason.
+	EventFailed EventKind = "failed"
+	// EventRemoved: Cleanup deleted File, which the release dropped.
+	EventRemoved EventKind = "removed"
+)
+
+// Event reports the progress of an update.
+type Event struct {
+	Kind    EventKind
+	File    File
+	Attempt int
+	Err     error
+}
+
+// EventSink receives the events of a Client. Events of concurrent
+// downloads are delivered from several goroutines at once, so Event must
+// be safe for concurrent use and shouldn't block.
+type EventSink interface {
+	Event(Event)
+}
+
+// SinkFunc adapts a function to an EventSink.
+type SinkFunc func(Event)
+
+func (f SinkFunc) Event(e Event) {
+	f(e)
+}
@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, 32*1024) // 32KB buffers
+	},
+}
+
+// SafePath reports whether a manifest path stays inside the install root.
+func SafePath(p string) bool {
+	if p == "" || strings.Contains(p, `\`) || path.IsAbs(p) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean == p && clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// Path returns the location of a manifest path below Root.
+func (c *Client) Path(rel string) string {
+	return filepath.Join(c.Root, filepath.FromSlash(rel))
+}
+
+// HashFile returns the hex md5 and the size of a file.
+func HashFile(filePath string) (string, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	buf := bufferPool.Get().([]byte)
+	defer bufferPool.Put(buf)
+	size, err := io.CopyBuffer(hash, file, buf)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Install writes the contents of r to the file's path under Root. The data
+// goes to a temporary file next to the target first and only replaces the
+// target once its size and hashes match the manifest, so an interrupted or
+// corrupt transfer never leaves a broken file behind. An empty sha256 skips
+// that check.
+func (c *Client) Install(file File, sha256Hash string, r io.Reader) error {
+	if !SafePath(file.Path) {
+		return fmt.Errorf("invalid path in manifest: %q", file.Path)
+	}
+	target := c.Path(file.Path)
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	md5Hash := md5.New()
+	shaHash := sha256.New()
+	buf := bufferPool.Get().([]byte)
+	size, err := io.CopyBuffer(io.MultiWriter(tmp, md5Hash, shaHash), r, buf)
+	bufferPool.Put(buf)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if size != file.Size {
+		return fmt.Errorf("%s: size mismatch: got %d bytes, expected %d", file.Path, size, file.Size)
+	}
+	if got := hex.EncodeToString(md5Hash.Sum(nil)); file.Hash != "" && got != file.Hash {
+		return fmt.Errorf("%s: hash mismatch", file.Path)
+	}
+	if got := hex.EncodeToString(shaHash.Sum(nil)); sha256Hash != "" && got != sha256Hash {
+		return fmt.Errorf("%s: sha256 mismatch", file.Path)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(tmpName, 0755); err != nil {
+			c.log().Warn("setting exec permission failed", "path", file.Path, "err", err)
+		}
+	}
+
+	return os.Rename(tmpName, target)
+}
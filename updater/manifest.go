@@ -0,0 +1,355 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"net/http"
+)
+
+// File is an entry of a release manifest. Its fields have no JSON tags, so
+// that manifests recorded by earlier patchers keep loading; the server's
+// lower-case keys match them as well.
+type File struct {
+	Hash string
+	Path string
+	Size int64
+	OS   string
+	Arch string
+	// Source is the path the payload is stored under on the server, when
+	// it differs from Path.
+	Source string
+	Group  string
+}
+
+// source returns the path of the file's payload on the server.
+func (f File) source() string {
+	if f.Source != "" {
+		return f.Source
+	}
+	return f.Path
+}
+
+// Meta is the summary of a release served by /meta.
+type Meta struct {
+	Hash      string   `json:"hash"`
+	TotalSize int64    `json:"totalSize"`
+	Groups    []string `json:"groups,omitempty"`
+}
+
+// Group is an optional component of a release.
+type Group struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Default     bool   `json:"default"`
+	Size        int64  `json:"size"`
+	Files       int    `json:"files"`
+}
+
+// Manifest is the list of files of a release served by /filesmeta.
+type Manifest struct {
+	Files  []File  `json:"files"`
+	Groups []Group `json:"groups"`
+	// Blobs is set when the backend serves every file by its hash from
+	// /blobs/; see Client.Blobs.
+	Blobs bool `json:"blobs"`
+}
+
+// Meta fetches the summary of the release for the client's platform.
+func (c *Client) Meta(ctx context.Context) (Meta, error) {
+	var meta Meta
+	resp, err := c.Get(ctx, c.releasePath("/meta")+c.platformQuery())
+	if err != nil {
+		return meta, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return meta, &StatusError{resp.StatusCode}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return meta, err
+	}
+	json.Unmarshal(body, &meta)
+	return meta, nil
+}
+
+// Manifest fetches the files of the release for the client's platform, in
+// the streamed format when the backend has it.
+func (c *Client) Manifest(ctx context.Context) (*Manifest, error) {
+	r, err := c.OpenManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	manifest := &Manifest{Groups: r.Groups, Blobs: r.Blobs}
+	for {
+		file, err := r.Next()
+		if err == io.EOF {
+			return manifest, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+}
+
+// ManifestReader returns the files of a release one at a time, so that a
+// release doesn't have to be held in memory to be checked. Streamed
+// manifests are decoded as they are read; JSON ones are read whole.
+type ManifestReader struct {
+	Groups []Group
+	// Blobs is Manifest.Blobs.
+	Blobs bool
+
+	body   io.Closer
+	stream *manifestReader
+	files  []File
+}
+
+// OpenManifest starts reading the files of the release for the client's
+// platform. The reader must be closed.
+func (c *Client) OpenManifest(ctx context.Context) (*ManifestReader, error) {
+	resp, err := c.Get(ctx, c.releasePath("/filesmeta")+c.platformQuery()+"&format=v1")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{resp.StatusCode}
+	}
+
+	if resp.Header.Get("Content-Type") == manifestContentType {
+		stream, err := newManifestReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return &ManifestReader{Groups: stream.groups, Blobs: stream.blobs, body: resp.Body, stream: stream}, nil
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	json.Unmarshal(body, &manifest)
+	return &ManifestReader{Groups: manifest.Groups, Blobs: manifest.Blobs, files: manifest.Files}, nil
+}
+
+// Next returns the next file, or io.EOF after the last one. A streamed
+// manifest is only known to be complete and intact once Next returned
+// io.EOF.
+func (r *ManifestReader) Next() (File, error) {
+	if r.stream != nil {
+		return r.stream.next()
+	}
+	if len(r.files) == 0 {
+		return File{}, io.EOF
+	}
+	file := r.files[0]
+	r.files = r.files[1:]
+	return file, nil
+}
+
+// Close releases the response the manifest is read from.
+func (r *ManifestReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// OverallHash is the hash /meta reports for a list of files, in the order
+// given.
+func OverallHash(files []File) string {
+	hash := md5.New()
+	for _, file := range files {
+		hashEntry(hash, file)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// manifestContentType marks a /filesmeta response in the streamed format,
+// which Manifest asks for with ?format=v1. Backends that don't know the
+// format ignore the parameter and answer with JSON. The layout is described
+// in server/manifest.go.
+const (
+	manifestContentType = "application/x-ppatcher-manifest"
+	manifestMagic       = "PPM1"
+)
+
+// Limits that keep a corrupt stream from allocating huge buffers.
+const (
+	maxManifestHeader = 16 << 20
+	maxManifestField  = 64 << 10
+)
+
+var errManifestCorrupt = errors.New("corrupt manifest stream")
+
+// manifestReader decodes a streamed manifest one entry at a time, checking
+// the order of the entries and, at the end, the overall hash.
+type manifestReader struct {
+	r        *bufio.Reader
+	groups   []Group
+	blobs    bool
+	previous string
+	overall  hash.Hash
+	done     bool
+}
+
+// newManifestReader reads the stream's header. The groups are available
+// right away, the entries through next.
+func newManifestReader(r io.Reader) (*manifestReader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifestReader{r: bufio.NewReader(zr), overall: md5.New()}
+
+	magic := make([]byte, len(manifestMagic))
+	if _, err := io.ReadFull(m.r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != manifestMagic {
+		return nil, fmt.Errorf("unsupported manifest format %q", magic)
+	}
+	header, err := m.bytes(maxManifestHeader)
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Groups []Group `json:"groups"`
+		Blobs  bool    `json:"blobs"`
+	}
+	if err := json.Unmarshal(header, &payload); err != nil {
+		return nil, err
+	}
+	m.groups = payload.Groups
+	m.blobs = payload.Blobs
+	return m, nil
+}
+
+// next returns the next entry, or io.EOF after the last one once the
+// overall hash matched.
+func (m *manifestReader) next() (File, error) {
+	if m.done {
+		return File{}, io.EOF
+	}
+	shared, err := binary.ReadUvarint(m.r)
+	if err != nil {
+		return File{}, m.unexpected(err)
+	}
+	if shared > uint64(len(m.previous)) {
+		return File{}, errManifestCorrupt
+	}
+	suffix, err := m.bytes(maxManifestField)
+	if err != nil {
+		return File{}, err
+	}
+	path := m.previous[:shared] + string(suffix)
+	if path == "" {
+		return File{}, m.finish()
+	}
+	if path <= m.previous {
+		return File{}, fmt.Errorf("manifest out of order or duplicate at %q", path)
+	}
+
+	file := File{Path: path}
+	sum, err := m.bytes(maxManifestField)
+	if err != nil {
+		return File{}, err
+	}
+	file.Hash = hex.EncodeToString(sum)
+	size, err := binary.ReadUvarint(m.r)
+	if err != nil {
+		return File{}, m.unexpected(err)
+	}
+	if size > math.MaxInt64 {
+		return File{}, errManifestCorrupt
+	}
+	file.Size = int64(size)
+	for _, field := range []*string{&file.OS, &file.Arch, &file.Source, &file.Group} {
+		value, err := m.bytes(maxManifestField)
+		if err != nil {
+			return File{}, err
+		}
+		*field = string(value)
+	}
+
+	hashEntry(m.overall, file)
+	m.previous = path
+	return file, nil
+}
+
+// finish checks the overall hash that follows the last entry.
+func (m *manifestReader) finish() error {
+	sum := make([]byte, md5.Size)
+	if _, err := io.ReadFull(m.r, sum); err != nil {
+		return m.unexpected(err)
+	}
+	if !bytes.Equal(sum, m.overall.Sum(nil)) {
+		return fmt.Errorf("manifest hash mismatch")
+	}
+	// Reading to the end verifies the gzip checksum.
+	if n, err := io.Copy(io.Discard, m.r); err != nil {
+		return m.unexpected(err)
+	} else if n > 0 {
+		return errManifestCorrupt
+	}
+	m.done = true
+	return io.EOF
+}
+
+// bytes reads a length-prefixed field of at most limit bytes.
+func (m *manifestReader) bytes(limit uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(m.r)
+	if err != nil {
+		return nil, m.unexpected(err)
+	}
+	if n > limit {
+		return nil, errManifestCorrupt
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(m.r, b); err != nil {
+		return nil, m.unexpected(err)
+	}
+	return b, nil
+}
+
+// unexpected turns the end of the stream before the trailer into an
+// error, as the manifest was cut short.
+func (m *manifestReader) unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// hashEntry adds a file to an overall hash.
+func hashEntry(h hash.Hash, fileMeta File) {
+	// Include file path, hash, and size in the overall hash calculation
+	h.Write([]byte(fileMeta.Path))
+	h.Write([]byte(fileMeta.Hash))
+
+	// Write size as 8 bytes (int64)
+	size := fileMeta.Size
+	for i := 0; i < 8; i++ {
+		h.Write([]byte{byte(size)})
+		size >>= 8
+	}
+}
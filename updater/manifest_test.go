@@ -0,0 +1,171 @@
+package updater
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodeManifest writes files as a streamed manifest with the given JSON
+// header, in the order given, followed by end.
+func encodeManifest(t *testing.T, header string, files []File, end []byte) []byte {
+	t.Helper()
+	var raw bytes.Buffer
+	field := func(b []byte) {
+		raw.Write(binary.AppendUvarint(nil, uint64(len(b))))
+		raw.Write(b)
+	}
+	raw.WriteString(manifestMagic)
+	field([]byte(header))
+	previous := ""
+	for _, file := range files {
+		shared := 0
+		for shared < len(previous) && shared < len(file.Path) && previous[shared] == file.Path[shared] {
+			shared++
+		}
+		raw.Write(binary.AppendUvarint(nil, uint64(shared)))
+		field([]byte(file.Path[shared:]))
+		sum, err := hex.DecodeString(file.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		field(sum)
+		raw.Write(binary.AppendUvarint(nil, uint64(file.Size)))
+		for _, value := range []string{file.OS, file.Arch, file.Source, file.Group} {
+			field([]byte(value))
+		}
+		previous = file.Path
+	}
+	raw.Write(end)
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	zw.Write(raw.Bytes())
+	zw.Close()
+	return out.Bytes()
+}
+
+// manifestTrailer is the end of a stream listing files: an empty path and
+// their overall hash.
+func manifestTrailer(files []File) []byte {
+	h := md5.New()
+	for _, file := range files {
+		hashEntry(h, file)
+	}
+	return append([]byte{0, 0}, h.Sum(nil)...)
+}
+
+// readManifest reads a stream to the end and returns the files before the
+// first error.
+func readManifest(t *testing.T, stream []byte) (*manifestReader, []File, error) {
+	t.Helper()
+	m, err := newManifestReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("reading header: %v", err)
+	}
+	var files []File
+	for {
+		file, err := m.next()
+		if err != nil {
+			return m, files, err
+		}
+		files = append(files, file)
+	}
+}
+
+var testFiles = []File{
+	{Path: "bin/game", Hash: "0123456789abcdef0123456789abcdef", Size: 4096, OS: "linux", Source: "_platforms/linux/bin/game"},
+	{Path: "bin/tool", Hash: "fedcba9876543210fedcba9876543210", Size: 12},
+	{Path: "data/hd.pak", Hash: "00112233445566778899aabbccddeeff", Size: 1 << 40, Group: "hd"},
+}
+
+func TestManifestReader(t *testing.T) {
+	stream := encodeManifest(t, `{"groups":[{"id":"hd","name":"HD textures"}],"blobs":true}`, testFiles, manifestTrailer(testFiles))
+	m, files, err := readManifest(t, stream)
+	if err != io.EOF {
+		t.Fatalf("got error %v, want io.EOF", err)
+	}
+	if !reflect.DeepEqual(files, testFiles) {
+		t.Errorf("got files %+v, want %+v", files, testFiles)
+	}
+	if len(m.groups) != 1 || m.groups[0].ID != "hd" || !m.blobs {
+		t.Errorf("got groups %+v and blobs %v from the header", m.groups, m.blobs)
+	}
+	if _, err := m.next(); err != io.EOF {
+		t.Errorf("reading past the end: got %v, want io.EOF", err)
+	}
+}
+
+func TestManifestReaderRejects(t *testing.T) {
+	reversed := []File{testFiles[1], testFiles[0]}
+	duplicate := []File{testFiles[0], testFiles[1], testFiles[1]}
+	tests := []struct {
+		name  string
+		files []File
+		end   []byte
+		read  int
+		check func(error) bool
+	}{
+		{"out of order", reversed, manifestTrailer(reversed), 1, func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "out of order")
+		}},
+		{"duplicate", duplicate, manifestTrailer(duplicate), 2, func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "duplicate")
+		}},
+		{"truncated before the trailer", testFiles, nil, 3, func(err error) bool {
+			return errors.Is(err, io.ErrUnexpectedEOF)
+		}},
+		{"truncated hash", testFiles, manifestTrailer(testFiles)[:10], 3, func(err error) bool {
+			return errors.Is(err, io.ErrUnexpectedEOF)
+		}},
+		{"hash mismatch", testFiles, manifestTrailer(testFiles[:2]), 3, func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "hash mismatch")
+		}},
+		{"data after the trailer", testFiles, append(manifestTrailer(testFiles), 0), 3, func(err error) bool {
+			return errors.Is(err, errManifestCorrupt)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, files, err := readManifest(t, encodeManifest(t, `{}`, test.files, test.end))
+			if !test.check(err) {
+				t.Errorf("got error %v", err)
+			}
+			if len(files) != test.read {
+				t.Errorf("read %d files before the error, want %d", len(files), test.read)
+			}
+		})
+	}
+}
+
+func TestOpenManifest(t *testing.T) {
+	stream := encodeManifest(t, `{"blobs":true}`, testFiles, manifestTrailer(testFiles))
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/filesmeta" || r.URL.Query().Get("format") != "v1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", manifestContentType)
+		w.Write(stream)
+	}))
+	defer backend.Close()
+
+	client := &Client{Backends: []string{backend.URL}}
+	manifest, err := client.Manifest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(manifest.Files, testFiles) || !manifest.Blobs {
+		t.Errorf("got manifest %+v", manifest)
+	}
+}
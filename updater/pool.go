@@ -0,0 +1,192 @@
+package updater
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// downloadAdjustInterval is how often the pool measures its throughput and
+// resizes.
+const downloadAdjustInterval = 2 * time.Second
+
+// downloadLimits returns the bounds of the download pool with defaults
+// applied.
+func (c *Client) downloadLimits() (min, max int) {
+	min, max = c.MinDownloads, c.MaxDownloads
+	if max <= 0 {
+		max = DefaultMaxDownloads
+	}
+	if min <= 0 {
+		min = DefaultMinDownloads
+	}
+	if min > max {
+		min = max
+	}
+	return min, max
+}
+
+// downloadPool limits how many files are fetched at once. The limit starts
+// at min and is adjusted every downloadAdjustInterval: it grows by one while
+// that raises the throughput, steps back when it didn't, and halves when
+// downloads fail, never leaving [min, max].
+type downloadPool struct {
+	min, max int
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+
+	// received and failures count since the last adjustment.
+	received int64
+	failures int64
+
+	// lastRate is the throughput in bytes per second at the previous
+	// adjustment, and grew whether the limit was raised then.
+	lastRate float64
+	grew     bool
+	// hold is the number of adjustments to wait before growing again
+	// after a step back.
+	hold int
+
+	done chan struct{}
+}
+
+func newDownloadPool(min, max int, logger *slog.Logger) *downloadPool {
+	d := &downloadPool{min: min, max: max, logger: logger, limit: min, done: make(chan struct{})}
+	d.cond = sync.NewCond(&d.mu)
+	go d.adjustLoop()
+	return d
+}
+
+// acquire blocks until fewer than limit downloads are active.
+func (d *downloadPool) acquire() {
+	d.mu.Lock()
+	for d.active >= d.limit {
+		d.cond.Wait()
+	}
+	d.active++
+	d.mu.Unlock()
+}
+
+func (d *downloadPool) release() {
+	d.mu.Lock()
+	d.active--
+	d.mu.Unlock()
+	d.cond.Signal()
+}
+
+// failed records a failed download attempt.
+func (d *downloadPool) failed() {
+	atomic.AddInt64(&d.failures, 1)
+}
+
+// count returns r with every byte read counted toward the throughput.
+func (d *downloadPool) count(r io.Reader) io.Reader {
+	return countingReader{r, &d.received}
+}
+
+// run calls install for each file, in order, on as many workers as the
+// limit allows, and returns once all of them are done.
+func (d *downloadPool) run(files []File, install func(File)) {
+	queue := make(chan File)
+	var wg sync.WaitGroup
+	for i := 0; i < d.max && i < len(files); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// A worker only takes the next file once the pool has
+				// room, so the queue's order is kept.
+				d.acquire()
+				file, ok := <-queue
+				if !ok {
+					d.release()
+					return
+				}
+				install(file)
+				d.release()
+			}
+		}()
+	}
+	for _, file := range files {
+		queue <- file
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// stop ends the adjustments. Downloads still queued keep the last limit.
+func (d *downloadPool) stop() {
+	close(d.done)
+}
+
+// adjustLoop calls adjust every downloadAdjustInterval until stop.
+func (d *downloadPool) adjustLoop() {
+	ticker := time.NewTicker(downloadAdjustInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-d.done:
+			return
+		case now := <-ticker.C:
+			d.adjust(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+func (d *downloadPool) adjust(elapsed time.Duration) {
+	received := atomic.SwapInt64(&d.received, 0)
+	failures := atomic.SwapInt64(&d.failures, 0)
+	rate := float64(received) / elapsed.Seconds()
+
+	d.mu.Lock()
+	previous := d.limit
+	switch {
+	case failures > 0:
+		d.limit = max(d.min, d.limit/2)
+		d.grew, d.hold = false, 3
+	case received == 0:
+		// Only local files were checked; there is nothing to measure.
+	case d.grew && rate < d.lastRate*1.05:
+		d.limit = max(d.min, d.limit-1)
+		d.grew, d.hold = false, 3
+	case d.hold > 0:
+		d.hold--
+		d.grew = false
+	case d.limit < d.max:
+		d.limit++
+		d.grew = true
+	default:
+		d.grew = false
+	}
+	if received > 0 {
+		d.lastRate = rate
+	}
+	limit := d.limit
+	d.mu.Unlock()
+
+	if limit > previous {
+		d.cond.Broadcast()
+	}
+	if limit != previous {
+		d.logger.Debug("download concurrency adjusted", "from", previous, "to", limit, "bytesPerSecond", int64(rate), "failures", failures)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,474 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDownloadAttempts is how often a file is tried before Download gives
+// up on it.
+const maxDownloadAttempts = 3
+
+// Change is a manifest entry whose installed copy is missing or differs.
+type Change struct {
+	File File
+	// LocalHash and LocalSize describe the installed copy. LocalHash is
+	// empty when there is none.
+	LocalHash string
+	LocalSize int64
+}
+
+// Plan is what Check found: the changes, along with the groups of the
+// release and whether it is served by hash. The files of the manifest are
+// compared as they are read and not kept; callers that need them read the
+// manifest themselves with OpenManifest.
+type Plan struct {
+	Groups []Group
+	// Blobs is Manifest.Blobs.
+	Blobs   bool
+	Changes []Change
+}
+
+// Files returns the files that have to be downloaded.
+func (p *Plan) Files() []File {
+	return ChangedFiles(p.Changes)
+}
+
+// ChangedFiles returns the files of changes.
+func ChangedFiles(changes []Change) []File {
+	files := make([]File, len(changes))
+	for i, change := range changes {
+		files[i] = change.File
+	}
+	return files
+}
+
+// DownloadError lists the files Download couldn't install, with the last
+// reason for each.
+type DownloadError struct {
+	Failed map[string]error
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("%d files failed to download", len(e.Failed))
+}
+
+// Check fetches the manifest and compares it with the installed files.
+func (c *Client) Check(ctx context.Context) (*Plan, error) {
+	return c.check(ctx, nil)
+}
+
+// check is Check, calling listed with every file of the manifest.
+func (c *Client) check(ctx context.Context, listed func(File)) (*Plan, error) {
+	r, err := c.OpenManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	next := r.Next
+	if listed != nil {
+		next = func() (File, error) {
+			file, err := r.Next()
+			if err == nil {
+				listed(file)
+			}
+			return file, err
+		}
+	}
+	changes, err := c.compare(ctx, next, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Groups: r.Groups, Blobs: r.Blobs, Changes: changes}, nil
+}
+
+// Diff hashes the installed copies of files and returns the ones that are
+// missing or differ, in the order given. Every file that matches is
+// reported as EventUpToDate.
+func (c *Client) Diff(ctx context.Context, files []File) ([]Change, error) {
+	return c.compare(ctx, listFiles(files), true)
+}
+
+// DiffManifest is Diff for the files of a manifest being read. The files
+// are hashed as they arrive and only the changes are kept.
+func (c *Client) DiffManifest(ctx context.Context, r *ManifestReader) ([]Change, error) {
+	return c.compare(ctx, r.Next, true)
+}
+
+// Verify is Diff without events: it returns the files whose installed copy
+// is missing or differs.
+func (c *Client) Verify(ctx context.Context, files []File) ([]File, error) {
+	changes, err := c.compare(ctx, listFiles(files), false)
+	if err != nil {
+		return nil, err
+	}
+	return ChangedFiles(changes), nil
+}
+
+// listFiles returns the files one at a time, like ManifestReader.Next.
+func listFiles(files []File) func() (File, error) {
+	return func() (File, error) {
+		if len(files) == 0 {
+			return File{}, io.EOF
+		}
+		file := files[0]
+		files = files[1:]
+		return file, nil
+	}
+}
+
+// compare hashes the files next returns until io.EOF, on every CPU.
+func (c *Client) compare(ctx context.Context, next func() (File, error), events bool) ([]Change, error) {
+	type change struct {
+		Change
+		index int
+	}
+	var mu sync.Mutex
+	var changes []change
+	var readErr error
+	read := 0
+	// take returns the next file and its position in the manifest.
+	take := func() (File, int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if readErr != nil || ctx.Err() != nil {
+			return File{}, 0, false
+		}
+		file, err := next()
+		if err != nil {
+			if err != io.EOF {
+				readErr = err
+			}
+			return File{}, 0, false
+		}
+		read++
+		return file, read - 1, true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				file, index, ok := take()
+				if !ok {
+					return
+				}
+				var hash string
+				var size int64
+				if SafePath(file.Path) {
+					var err error
+					if hash, size, err = HashFile(c.Path(file.Path)); err != nil && !os.IsNotExist(err) {
+						c.log().Debug("hashing local file failed", "path", file.Path, "err", err)
+					}
+				}
+				if hash != "" && hash == file.Hash {
+					if events {
+						c.emit(Event{Kind: EventUpToDate, File: file})
+					}
+					continue
+				}
+				mu.Lock()
+				changes = append(changes, change{Change{File: file, LocalHash: hash, LocalSize: size}, index})
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].index < changes[j].index
+	})
+	var differing []Change
+	for _, change := range changes {
+		differing = append(differing, change.Change)
+	}
+	return differing, nil
+}
+
+// Download fetches and installs files. The largest files go first and the
+// Last paths at the very end; small files are fetched together through
+// /batch where the backend has it. Every file is verified before it
+// replaces the installed copy, and failed files are retried. The ones that
+// still fail are reported as EventFailed and returned in a *DownloadError
+// once the others are installed.
+func (c *Client) Download(ctx context.Context, files []File) error {
+	min, max := c.downloadLimits()
+	pool := newDownloadPool(min, max, c.log())
+	defer pool.stop()
+	batch := c.newDownloadBatch()
+
+	var mu sync.Mutex
+	failed := map[string]error{}
+	fail := func(file File, err error) {
+		c.log().Error("downloading file failed", "path", file.Path, "err", err)
+		mu.Lock()
+		failed[file.Path] = err
+		mu.Unlock()
+		c.emit(Event{Kind: EventFailed, File: file, Err: err})
+	}
+
+	fetch := func(file File) {
+		c.emit(Event{Kind: EventDownloading, File: file})
+		err := c.downloadFile(ctx, file, pool)
+		for attempt := 2; err != nil && attempt <= maxDownloadAttempts && !errors.Is(err, ErrLicenseRequired) && ctx.Err() == nil; attempt++ {
+			c.log().Warn("retrying download", "path", file.Path, "attempt", attempt, "err", err)
+			c.emit(Event{Kind: EventRetrying, File: file, Attempt: attempt, Err: err})
+			select {
+			case <-time.After(time.Duration(attempt-1) * time.Second):
+			case <-ctx.Done():
+			}
+			err = c.downloadFile(ctx, file, pool)
+		}
+		if err != nil {
+			fail(file, err)
+			return
+		}
+		c.emit(Event{Kind: EventDownloaded, File: file})
+	}
+
+	// fetchBatch downloads files with one request and falls back to
+	// fetching the ones it didn't install.
+	fetchBatch := func(files []File) {
+		for _, file := range files {
+			c.emit(Event{Kind: EventDownloading, File: file})
+		}
+		installed, err := c.downloadBatch(ctx, files, pool)
+		if errors.Is(err, errBatchUnsupported) {
+			c.log().Info("backend has no batch downloads")
+			batch.disable()
+		} else if err != nil {
+			c.log().Warn("batch download failed", "files", len(files), "err", err)
+		}
+		for _, file := range files {
+			if installed[file.Path] {
+				c.emit(Event{Kind: EventDownloaded, File: file})
+			} else {
+				fetch(file)
+			}
+		}
+	}
+
+	install := func(file File) {
+		if err := ctx.Err(); err != nil {
+			fail(file, err)
+			return
+		}
+		if queued, full := batch.add(file); queued {
+			if full != nil {
+				fetchBatch(full)
+			}
+			return
+		}
+		fetch(file)
+	}
+
+	// flush downloads the small files still waiting for a batch.
+	flush := func() {
+		if files := batch.take(); len(files) > 0 {
+			pool.acquire()
+			fetchBatch(files)
+			pool.release()
+		}
+	}
+
+	others, last := c.order(files)
+	pool.run(others, install)
+	flush()
+	pool.run(last, install)
+	flush()
+
+	if len(failed) > 0 {
+		return &DownloadError{Failed: failed}
+	}
+	return nil
+}
+
+// downloadFile fetches and installs a single file. Its bytes and failures
+// are counted by pool.
+func (c *Client) downloadFile(ctx context.Context, file File, pool *downloadPool) error {
+	c.log().Debug("downloading file", "path", file.Path)
+
+	// Platform-specific files are stored under a different path on the
+	// server than the one they are installed to.
+	source := c.releasePath("/files/" + file.source())
+	if c.Blobs && blobHash(file.Hash) {
+		source = "/blobs/" + file.Hash
+	}
+	resp, err := c.getFile(ctx, source)
+	if err != nil {
+		pool.failed()
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		// Drain the error page so the connection can be reused.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		pool.failed()
+		return &StatusError{resp.StatusCode}
+	}
+
+	if err := c.Install(file, "", pool.count(resp.Body)); err != nil {
+		pool.failed()
+		return err
+	}
+	return nil
+}
+
+// blobHash reports whether hash can name a blob: 32 lower-case hex digits,
+// which keeps it from leaving /blobs/.
+func blobHash(hash string) bool {
+	if len(hash) != 32 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// order sorts files largest first, so that a big file doesn't start last
+// and keep the update running on a single connection. The Last paths are
+// split off to be downloaded at the very end.
+func (c *Client) order(files []File) (others, last []File) {
+	names := map[string]bool{}
+	for _, p := range c.Last {
+		p = strings.TrimSpace(p)
+		if p != "" && !filepath.IsAbs(p) {
+			names[pathKey(path.Clean(filepath.ToSlash(p)))] = true
+		}
+	}
+
+	for _, file := range files {
+		if names[pathKey(file.Path)] {
+			last = append(last, file)
+		} else {
+			others = append(others, file)
+		}
+	}
+	sort.SliceStable(others, func(i, j int) bool {
+		return others[i].Size > others[j].Size
+	})
+	return others, last
+}
+
+// pathKey compares paths the way the file system does.
+func pathKey(p string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
+// Dropped returns the files of previous that current no longer lists.
+// Paths that would leave the install root are left out.
+func Dropped(previous, current []File) []File {
+	listed := make(map[string]bool, len(current))
+	for _, file := range current {
+		listed[file.Path] = true
+	}
+	var dropped []File
+	for _, file := range previous {
+		if !listed[file.Path] && SafePath(file.Path) {
+			dropped = append(dropped, file)
+		}
+	}
+	return dropped
+}
+
+// Cleanup deletes the installed files that previous lists and current
+// doesn't, reporting each as EventRemoved. It returns the first error but
+// tries every file.
+func (c *Client) Cleanup(previous, current []File) error {
+	return c.remove(Dropped(previous, current))
+}
+
+func (c *Client) remove(files []File) error {
+	var first error
+	for _, file := range files {
+		err := os.Remove(c.Path(file.Path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			c.log().Warn("removing dropped file failed", "path", file.Path, "err", err)
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		c.emit(Event{Kind: EventRemoved, File: file})
+	}
+	return first
+}
+
+// Update brings Root in line with the release: it runs Check and Download
+// and, given the manifest the installed files came from, Cleanup. The
+// manifest is streamed, never held whole. Update returns Check's plan, also
+// when some files failed to download. Blobs is set from the manifest.
+func (c *Client) Update(ctx context.Context, previous []File) (*Plan, error) {
+	unlisted := make(map[string]bool, len(previous))
+	for _, file := range previous {
+		unlisted[file.Path] = true
+	}
+	plan, err := c.check(ctx, func(file File) {
+		delete(unlisted, file.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Blobs = plan.Blobs
+	if err := c.Download(ctx, plan.Files()); err != nil {
+		return plan, err
+	}
+	var dropped []File
+	for _, file := range previous {
+		if unlisted[file.Path] && SafePath(file.Path) {
+			dropped = append(dropped, file)
+		}
+	}
+	return plan, c.remove(dropped)
+}
+
+// Launch starts executable, relative to Root unless absolute, with Root as
+// its working directory. It doesn't wait for the process to exit.
+func (c *Client) Launch(executable string, args ...string) (*exec.Cmd, error) {
+	executable = strings.TrimSpace(executable)
+	if executable == "" {
+		return nil, errors.New("executable path is empty")
+	}
+	if !filepath.IsAbs(executable) {
+		executable = c.Path(executable)
+	}
+	if _, err := os.Stat(executable); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = c.Root
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
@@ -0,0 +1,153 @@
+package updater
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBackend serves a release made of contents, by path, as /meta,
+// /filesmeta and /files/. Its manifest is sorted by path like the server's.
+func fakeBackend(t *testing.T, contents map[string]string, groups []Group) (*httptest.Server, []File) {
+	t.Helper()
+	var files []File
+	for path, content := range contents {
+		sum := md5.Sum([]byte(content))
+		files = append(files, File{Path: path, Hash: hex.EncodeToString(sum[:]), Size: int64(len(content))})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/meta":
+			var totalSize int64
+			for _, file := range files {
+				totalSize += file.Size
+			}
+			json.NewEncoder(w).Encode(Meta{Hash: OverallHash(files), TotalSize: totalSize})
+		case r.URL.Path == "/filesmeta":
+			w.Header().Set("Content-Type", manifestContentType)
+			header, _ := json.Marshal(map[string]interface{}{"groups": groups})
+			w.Write(encodeManifest(t, string(header), files, manifestTrailer(files)))
+		case strings.HasPrefix(r.URL.Path, "/files/"):
+			content, ok := contents[strings.TrimPrefix(r.URL.Path, "/files/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(backend.Close)
+	return backend, files
+}
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	backend, _ := fakeBackend(t, map[string]string{
+		"a.txt":     "same",
+		"b/c.txt":   "new",
+		"b/d.txt":   "changed",
+		"e/f/g.bin": "missing",
+	}, []Group{{ID: "hd"}})
+	root := t.TempDir()
+	writeFile(t, root, "a.txt", "same")
+	writeFile(t, root, "b/d.txt", "old")
+
+	// Files are hashed on every CPU, so events arrive concurrently.
+	var mu sync.Mutex
+	var events []Event
+	client := &Client{
+		Backends: []string{backend.URL},
+		Root:     root,
+		Sink: SinkFunc(func(event Event) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}),
+	}
+	plan, err := client.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, change := range plan.Changes {
+		paths = append(paths, change.File.Path)
+	}
+	if got, want := strings.Join(paths, " "), "b/c.txt b/d.txt e/f/g.bin"; got != want {
+		t.Errorf("got changes %s, want %s", got, want)
+	}
+	for _, change := range plan.Changes {
+		installed := change.File.Path == "b/d.txt"
+		if (change.LocalHash != "") != installed || (installed && change.LocalSize != 3) {
+			t.Errorf("%s: got local hash %q and size %d", change.File.Path, change.LocalHash, change.LocalSize)
+		}
+	}
+	if len(plan.Groups) != 1 || plan.Groups[0].ID != "hd" {
+		t.Errorf("got groups %+v", plan.Groups)
+	}
+	if len(events) != 1 || events[0].Kind != EventUpToDate || events[0].File.Path != "a.txt" {
+		t.Errorf("got events %+v, want a.txt up to date", events)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	backend, files := fakeBackend(t, map[string]string{
+		"a.txt":   "same",
+		"b/c.txt": "new",
+		"b/d.txt": "changed",
+	}, nil)
+	root := t.TempDir()
+	writeFile(t, root, "a.txt", "same")
+	writeFile(t, root, "b/d.txt", "old")
+	writeFile(t, root, "dropped.txt", "gone")
+	writeFile(t, root, "kept.txt", "not ours")
+
+	client := &Client{Backends: []string{backend.URL}, Root: root, BatchThreshold: -1}
+	// The installed manifest may list paths outside Root; those are left
+	// alone.
+	previous := []File{{Path: "a.txt"}, {Path: "b/d.txt"}, {Path: "dropped.txt"}, {Path: "../outside.txt"}}
+	plan, err := client.Update(context.Background(), previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 {
+		t.Errorf("got %d changes, want 2", len(plan.Changes))
+	}
+
+	changed, err := client.Verify(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("files still differ after the update: %+v", changed)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dropped.txt")); !os.IsNotExist(err) {
+		t.Errorf("dropped file wasn't removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "kept.txt")); err != nil {
+		t.Errorf("file the release never listed was touched: %v", err)
+	}
+}
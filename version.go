@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"ppatcher/semver"
+)
+
+// States of VersionStatus.
+const (
+	// versionUnknown: the backend hasn't reported a version yet.
+	versionUnknown = "unknown"
+	// versionCurrent: the latest release is installed.
+	versionCurrent = "current"
+	// versionOptional: a newer release can be installed, but the installed
+	// one may still be played.
+	versionOptional = "optional"
+	// versionRequired: the product can't be launched until the latest
+	// release is installed.
+	versionRequired = "required"
+	// versionNewer: the installed build is newer than the backend's
+	// release, as on test builds.
+	versionNewer = "newer"
+	// versionPinned: the install is pinned to a version chosen with
+	// InstallVersion and doesn't follow the latest release.
+	versionPinned = "pinned"
+)
+
+// VersionStatus compares the installed release with the backend's.
+type VersionStatus struct {
+	// Installed is the version of the installed files, or "" if they
+	// predate version tracking.
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	// MinimumVersion is the oldest release the backend still allows to
+	// launch.
+	MinimumVersion string `json:"minimumVersion"`
+	// Mandatory means the latest release must be installed to launch.
+	Mandatory bool `json:"mandatory"`
+	// Pinned is the version the install is pinned to, if any.
+	Pinned string `json:"pinned"`
+	State  string `json:"state"`
+}
+
+// VersionStatus returns the version status of the selected product.
+func (a *App) VersionStatus() VersionStatus {
+	return a.current().versionStatus()
+}
+
+// installedVersion returns the release version recorded in .downloadmeta.
+func (p *product) installedVersion() string {
+	if p.rootDir() == "" {
+		return ""
+	}
+	data, err := os.ReadFile(p.path(".downloadmeta"))
+	if err != nil {
+		return ""
+	}
+	var meta MetaData
+	json.Unmarshal(data, &meta)
+	return meta.Version
+}
+
+// versionStatus compares the installed release with the last version the
+// backend reported. Versions are compared as semantic versions; others
+// only tell whether they differ. Installs without a recorded version are
+// behind when their files differ from the backend's. Pinned installs only
+// have to respect the minimum version; a mandatory release doesn't apply to
+// them.
+func (p *product) versionStatus() VersionStatus {
+	p.mu.RLock()
+	status := VersionStatus{
+		Latest:         p.remoteVersion,
+		MinimumVersion: p.minimumVersion,
+		Mandatory:      p.mandatory,
+		Pinned:         p.pinned,
+		State:          versionUnknown,
+	}
+	outdated := p.outdated
+	p.mu.RUnlock()
+	status.Installed = p.installedVersion()
+	if status.Pinned != "" {
+		status.State = versionPinned
+		if p.belowMinimum(status.Pinned) {
+			status.State = versionRequired
+		}
+		return status
+	}
+	if status.Latest == "" {
+		return status
+	}
+
+	behind := outdated
+	if status.Installed != "" {
+		c, ok := semver.Compare(status.Installed, status.Latest)
+		if ok && c > 0 {
+			status.State = versionNewer
+			return status
+		}
+		behind = behind || (ok && c < 0) || (!ok && status.Installed != status.Latest)
+	}
+
+	switch {
+	case p.updateForced(status.Installed) && behind:
+		status.State = versionRequired
+	case behind:
+		status.State = versionOptional
+	default:
+		status.State = versionCurrent
+	}
+	return status
+}
+
+// updateForced reports whether the backend requires a newer release than
+// installed: the latest release is mandatory, or installed is below the
+// minimum version.
+func (p *product) updateForced(installed string) bool {
+	_, mandatory := p.versionPolicy()
+	return mandatory || p.belowMinimum(installed)
+}
+
+// belowMinimum reports whether version is older than the backend's minimum
+// version.
+func (p *product) belowMinimum(version string) bool {
+	minimum, _ := p.versionPolicy()
+	if minimum == "" || version == "" {
+		return false
+	}
+	c, ok := semver.Compare(version, minimum)
+	return ok && c < 0
+}
+
+// emitVersionStatus sends the version status as a "versionStatus" event.
+func (p *product) emitVersionStatus() {
+	p.emit("versionStatus", p.versionStatus())
+}